@@ -72,3 +72,49 @@ func getUniqueResourceName(name string, runID string) string {
 func getOutputSecretname(name string) string {
 	return fmt.Sprintf("%s-outputs", truncateResourceName(name, 220))
 }
+
+// getLockFileConfigMapName returns the name of the ConfigMap that holds the captured dependency lock file
+func getLockFileConfigMapName(name string) string {
+	return fmt.Sprintf("%s-lock", truncateResourceName(name, 220))
+}
+
+// getPlanJobName returns the name of the plan Job of a run with a separate plan
+func getPlanJobName(name string, runID string) string {
+	return fmt.Sprintf("%s-plan", getUniqueResourceName(name, runID))
+}
+
+// getPlanSecretName returns the name prefix of the Secrets the Terraform Runner saves the plan of a run in
+func getPlanSecretName(name string, runID string) string {
+	return fmt.Sprintf("%s-plan", getUniqueResourceName(name, runID))
+}
+
+// getPlanChunkSecretName returns the name of a chunk Secret of the plan of a run
+func getPlanChunkSecretName(name string, runID string, chunk int) string {
+	return fmt.Sprintf("%s-%d", getPlanSecretName(name, runID), chunk)
+}
+
+// getPlanChunkSecretNames returns the names of every chunk Secret the plan of a run can be saved in
+func getPlanChunkSecretNames(name string, runID string) []string {
+	names := []string{}
+
+	for i := 0; i < maxPlanChunks; i++ {
+		names = append(names, getPlanChunkSecretName(name, runID, i))
+	}
+
+	return names
+}
+
+// getRunStateConfigMapName returns the name of the ConfigMap the Terraform Runner records the state lock of the run in
+func getRunStateConfigMapName(name string) string {
+	return fmt.Sprintf("%s-run-state", truncateResourceName(name, 220))
+}
+
+// getStateSecretName returns the name of the secret the Kubernetes backend stores the state in
+func getStateSecretName(name string, workspace string) string {
+	return fmt.Sprintf("tfstate-%s-%s", workspace, name)
+}
+
+// getStateLockLeaseName returns the name of the lease the Kubernetes backend locks the state with
+func getStateLockLeaseName(stateSecretName string) string {
+	return fmt.Sprintf("lock-%s", stateSecretName)
+}
@@ -56,6 +56,11 @@ var _ = Describe("Helpers", func() {
 			Expect(name).To(Equal("foo-outputs"))
 		})
 
+		It("should return the the name of the lock file configmap", func() {
+			name := getLockFileConfigMapName("foo")
+			Expect(name).To(Equal("foo-lock"))
+		})
+
 		It("should return a truncated value if length of the name is larger than allowed", func() {
 			name := truncateResourceName("my-value-for-the-name", 15)
 			// should trim from the right and keep the string to 15 characters
@@ -2,16 +2,18 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/kuptan/terraform-operator/internal/kube"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
 // getConfigMapSpecForModule returns a Kubernetes ConifgMap spec for the terraform module
 // This configmap will be mounted in the Terraform Runner pod
-func getConfigMapSpecForModule(name string, namespace string, module string, runID string, owner metav1.OwnerReference) *corev1.ConfigMap {
+func getConfigMapSpecForModule(name string, namespace string, module string, lockFile string, runID string, owner metav1.OwnerReference) *corev1.ConfigMap {
 	cm := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      getUniqueResourceName(name, runID),
@@ -26,9 +28,60 @@ func getConfigMapSpecForModule(name string, namespace string, module string, run
 		},
 	}
 
+	if lockFile != "" {
+		cm.Data[lockFileKey] = lockFile
+	}
+
 	return cm
 }
 
+// getConfigMapKey returns the value of a key in a ConfigMap, an empty value is returned if the ConfigMap is not found
+func getConfigMapKey(ctx context.Context, name string, namespace string, key string) (string, bool, error) {
+	cm, err := kube.ClientSet.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", false, nil
+		}
+
+		return "", false, err
+	}
+
+	value, found := cm.Data[key]
+
+	return value, found, nil
+}
+
+// getLockFileContent returns the dependency lock file of the workflow/run. The lock file
+// defined in the spec takes precedence over the lock file captured from previous runs
+func getLockFileContent(ctx context.Context, run *Terraform) (string, error) {
+	lockFile := run.Spec.Module.LockFile
+
+	if lockFile != nil && lockFile.Inline != "" {
+		return lockFile.Inline, nil
+	}
+
+	if lockFile != nil && lockFile.ConfigMapKeyRef != nil {
+		ref := lockFile.ConfigMapKeyRef
+
+		value, found, err := getConfigMapKey(ctx, ref.Name, run.Namespace, ref.Key)
+
+		if err != nil {
+			return "", err
+		}
+
+		if !found && (ref.Optional == nil || !*ref.Optional) {
+			return "", fmt.Errorf("lock file key '%s' was not found in configmap '%s'", ref.Key, ref.Name)
+		}
+
+		return value, nil
+	}
+
+	value, _, err := getConfigMapKey(ctx, getLockFileConfigMapName(run.Name), run.Namespace, lockFileKey)
+
+	return value, err
+}
+
 // createConfigMapForModule creates the ConfigMap for the Terraform workflow/run
 func createConfigMapForModule(ctx context.Context, namespacedName types.NamespacedName, run *Terraform) (*corev1.ConfigMap, error) {
 	configMaps := kube.ClientSet.CoreV1().ConfigMaps(namespacedName.Namespace)
@@ -39,10 +92,16 @@ func createConfigMapForModule(ctx context.Context, namespacedName types.Namespac
 		return nil, err
 	}
 
+	lockFile, err := getLockFileContent(ctx, run)
+
+	if err != nil {
+		return nil, err
+	}
+
 	configMap := getConfigMapSpecForModule(
 		namespacedName.Name,
 		namespacedName.Namespace,
-		string(tpl), run.Status.RunID,
+		string(tpl), lockFile, run.Status.RunID,
 		run.GetOwnerReference())
 
 	if _, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
@@ -52,6 +111,92 @@ func createConfigMapForModule(ctx context.Context, namespacedName types.Namespac
 	return configMap, nil
 }
 
+// createConfigMapForLockFile creates a ConfigMap to capture the dependency lock file produced by the
+// Terraform Runner, the ConfigMap is shared between the workflows/runs of the Terraform object
+func createConfigMapForLockFile(ctx context.Context, namespacedName types.NamespacedName, run *Terraform) (*corev1.ConfigMap, error) {
+	configMaps := kube.ClientSet.CoreV1().ConfigMaps(namespacedName.Namespace)
+
+	name := getLockFileConfigMapName(namespacedName.Name)
+
+	existing, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+
+	if err == nil {
+		return existing, nil
+	}
+
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	obj := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespacedName.Namespace,
+			Labels:    getCommonLabels(namespacedName.Name, run.Status.RunID),
+			OwnerReferences: []metav1.OwnerReference{
+				run.GetOwnerReference(),
+			},
+		},
+		Data: map[string]string{},
+	}
+
+	configMap, err := configMaps.Create(ctx, obj, metav1.CreateOptions{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return configMap, nil
+}
+
+// createConfigMapForRunState creates or resets the ConfigMap the Terraform Runner records the state lock of the run in,
+// the ConfigMap is shared between the workflows/runs of the Terraform object
+func createConfigMapForRunState(ctx context.Context, namespacedName types.NamespacedName, run *Terraform) (*corev1.ConfigMap, error) {
+	configMaps := kube.ClientSet.CoreV1().ConfigMaps(namespacedName.Namespace)
+
+	name := getRunStateConfigMapName(namespacedName.Name)
+
+	existing, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+
+	if err == nil {
+		existing.Labels = getCommonLabels(namespacedName.Name, run.Status.RunID)
+		existing.Data = map[string]string{}
+
+		return configMaps.Update(ctx, existing, metav1.UpdateOptions{})
+	}
+
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	obj := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespacedName.Namespace,
+			Labels:    getCommonLabels(namespacedName.Name, run.Status.RunID),
+			OwnerReferences: []metav1.OwnerReference{
+				run.GetOwnerReference(),
+			},
+		},
+		Data: map[string]string{},
+	}
+
+	return configMaps.Create(ctx, obj, metav1.CreateOptions{})
+}
+
+// listConfigMapsForRun returns the ConfigMaps of all the runs of a Terraform object
+func listConfigMapsForRun(ctx context.Context, runName string, namespace string) ([]corev1.ConfigMap, error) {
+	list, err := kube.ClientSet.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("terraformRunName=%s", runName),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
 // deleteConfigMapByRun deletes the Kubernetes Job of the workflow/run
 func deleteConfigMapByRun(ctx context.Context, runName string, namespace string, runID string) error {
 	configMaps := kube.ClientSet.CoreV1().ConfigMaps(namespace)
@@ -3,10 +3,12 @@ package v1alpha1
 import (
 	"context"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
+	"github.com/kuptan/terraform-operator/internal/kube"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -68,4 +70,129 @@ var _ = Describe("Kubernetes ConfigMaps", func() {
 			Expect(errors.IsNotFound(err)).To(BeTrue())
 		})
 	})
+
+	Context("Dependency Lock File", func() {
+		var run *Terraform
+
+		newKey := func(name string) types.NamespacedName {
+			return types.NamespacedName{
+				Name:      name,
+				Namespace: "default",
+			}
+		}
+
+		createLockSource := func(name string, namespace string) {
+			source := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Data: map[string]string{
+					"lock": "# lock file from configmap",
+				},
+			}
+
+			_, err := kube.ClientSet.CoreV1().ConfigMaps(namespace).Create(context.Background(), source, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			run = &Terraform{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "default",
+				},
+				Spec: TerraformSpec{
+					TerraformVersion: "1.0.2",
+					Module: Module{
+						Source:  "IbraheemAlSaady/test/module",
+						Version: "0.0.2",
+						LockFile: &LockFile{
+							Inline: "# inline lock file",
+						},
+					},
+				},
+				Status: TerraformStatus{
+					RunID: "1234",
+				},
+			}
+		})
+
+		It("should add the inline lock file to the module configmap", func() {
+			key := newKey("locked-inline")
+			run.Name = key.Name
+
+			cfg, err := createConfigMapForModule(context.Background(), key, run)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Data[lockFileKey]).To(Equal("# inline lock file"))
+		})
+
+		It("should add the lock file from a configmap key", func() {
+			key := newKey("locked-ref")
+			run.Name = key.Name
+
+			createLockSource("lock-source", key.Namespace)
+
+			run.Spec.Module.LockFile = &LockFile{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: "lock-source",
+					},
+					Key: "lock",
+				},
+			}
+
+			cfg, err := createConfigMapForModule(context.Background(), key, run)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Data[lockFileKey]).To(Equal("# lock file from configmap"))
+		})
+
+		It("should fail if the lock file configmap key does not exist", func() {
+			key := newKey("locked-missing")
+			run.Name = key.Name
+
+			createLockSource("lock-source-missing", key.Namespace)
+
+			run.Spec.Module.LockFile = &LockFile{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: "lock-source-missing",
+					},
+					Key: "missing",
+				},
+			}
+
+			cfg, err := createConfigMapForModule(context.Background(), key, run)
+
+			Expect(err).To(HaveOccurred())
+			Expect(cfg).To(BeNil())
+		})
+
+		It("should use the captured lock file if not defined in the spec and not override it", func() {
+			key := newKey("locked-captured")
+			run.Name = key.Name
+			run.Spec.Module.LockFile = nil
+
+			captured, err := createConfigMapForLockFile(context.Background(), key, run)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(captured.Name).To(Equal("locked-captured-lock"))
+
+			captured.Data[lockFileKey] = "# captured lock file"
+
+			_, err = kube.ClientSet.CoreV1().ConfigMaps(key.Namespace).Update(context.Background(), captured, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			cfg, err := createConfigMapForModule(context.Background(), key, run)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Data[lockFileKey]).To(Equal("# captured lock file"))
+
+			captured, err = createConfigMapForLockFile(context.Background(), key, run)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(captured.Data[lockFileKey]).To(Equal("# captured lock file"))
+		})
+	})
 })
@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/kuptan/terraform-operator/internal/kube"
 	"github.com/kuptan/terraform-operator/internal/utils"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 const (
@@ -17,15 +20,59 @@ const (
 	moduleWorkingDirMountPath string = "/tmp/tfmodule"
 	conifgMapModuleMountPath  string = "/terraform/modules"
 	gitSSHKeyMountPath        string = "/root/.ssh"
+	lockFileKey               string = ".terraform.lock.hcl"
+
+	runnerContainerName string = "terraform"
 
 	knownHostsVolumeName string = "known-hosts"
 	emptyDirVolumeName   string = "tfmodule"
 	gitSSHKeyVolumeName  string = "git-ssh"
 )
 
-// getTerraformRunnerDockerImage returns the Docker image for the Terraform Runner
-func getTerraformRunnerDockerImage() string {
-	return fmt.Sprintf("%s/%s:%s", utils.Env.DockerRepository, utils.Env.TerraformRunnerImage, utils.Env.TerraformRunnerImageTag)
+// capabilities of the Terraform Runner image, a capability is only used if the operator lists it in
+// TERRAFORM_RUNNER_CAPABILITIES
+const (
+	runnerCapabilityStages      string = "stages"
+	runnerCapabilityForceUnlock string = "force-unlock"
+)
+
+// getTerraformRunnerDockerImage returns the Docker image for the Terraform Runner based on the engine, the
+// OpenTofu runner image must be configured to run the OpenTofu engine
+func getTerraformRunnerDockerImage(engine TerraformEngine) (string, error) {
+	if engine != EngineOpenTofu {
+		return fmt.Sprintf("%s/%s:%s", utils.Env.DockerRepository, utils.Env.TerraformRunnerImage, utils.Env.TerraformRunnerImageTag), nil
+	}
+
+	if utils.Env.OpenTofuRunnerImage == "" || utils.Env.OpenTofuRunnerImageTag == "" {
+		return "", fmt.Errorf("the opentofu engine is not available, OPENTOFU_RUNNER_IMAGE and OPENTOFU_RUNNER_IMAGE_TAG must be configured in the operator")
+	}
+
+	return fmt.Sprintf("%s/%s:%s", utils.Env.DockerRepository, utils.Env.OpenTofuRunnerImage, utils.Env.OpenTofuRunnerImageTag), nil
+}
+
+// ValidateEngine validates that the operator has a runner image for the engine of the run
+func (t *Terraform) ValidateEngine() error {
+	_, err := getTerraformRunnerDockerImage(t.GetEngine())
+
+	return err
+}
+
+// hasRunnerCapability evaluates if the Terraform Runner image supports a capability
+func hasRunnerCapability(capability string) bool {
+	return containsString(utils.Env.RunnerCapabilities, capability)
+}
+
+// ValidateRunnerCapabilities validates that the Terraform Runner image supports the capabilities the run needs
+func (t *Terraform) ValidateRunnerCapabilities() error {
+	if t.usesSeparatePlan() && !hasRunnerCapability(runnerCapabilityStages) {
+		return fmt.Errorf("the run needs a separate plan job (separatePlan, protection, policies or plan hooks) but the terraform runner does not support the '%s' capability in TERRAFORM_RUNNER_CAPABILITIES", runnerCapabilityStages)
+	}
+
+	if t.Spec.CancelPolicy != nil && t.Spec.CancelPolicy.ForceUnlock && !hasRunnerCapability(runnerCapabilityForceUnlock) {
+		return fmt.Errorf("the run enables forceUnlock but the terraform runner does not support the '%s' capability in TERRAFORM_RUNNER_CAPABILITIES", runnerCapabilityForceUnlock)
+	}
+
+	return nil
 }
 
 // getBusyboxDockerImage returns the busy box image
@@ -48,10 +95,15 @@ func getEnvVarKey(v Variable) string {
 func (t *Terraform) getRunnerSpecificEnvVars() []corev1.EnvVar {
 	envVars := []corev1.EnvVar{}
 
-	envVars = append(envVars, getEnvVariable("TERRAFORM_VERSION", t.Spec.TerraformVersion))
+	envVars = append(envVars, getEnvVariable("TERRAFORM_ENGINE", string(t.GetEngine())))
+	envVars = append(envVars, getEnvVariable("TERRAFORM_VERSION", t.getTerraformVersion()))
+	envVars = append(envVars, getEnvVariable("TERRAFORM_VERSION_CONSTRAINT", t.GetVersionConstraint()))
 	envVars = append(envVars, getEnvVariable("TERRAFORM_WORKING_DIR", moduleWorkingDirMountPath))
 	envVars = append(envVars, getEnvVariable("TERRAFORM_VAR_FILES_PATH", tfVarsMountPath))
 	envVars = append(envVars, getEnvVariable("OUTPUT_SECRET_NAME", getOutputSecretname(t.Name)))
+	envVars = append(envVars, getEnvVariable("LOCK_FILE_CONFIGMAP_NAME", getLockFileConfigMapName(t.Name)))
+	envVars = append(envVars, getEnvVariable("RUN_STATE_CONFIGMAP_NAME", getRunStateConfigMapName(t.Name)))
+	envVars = append(envVars, getEnvVariable("PLAN_SECRET_NAME", getPlanSecretName(t.Name, t.Status.RunID)))
 	envVars = append(envVars, getEnvVariable("TERRAFORM_DESTROY", strconv.FormatBool(t.Spec.Destroy)))
 
 	envVars = append(envVars, getEnvVariableFromFieldSelector("POD_NAMESPACE", "metadata.namespace"))
@@ -60,6 +112,15 @@ func (t *Terraform) getRunnerSpecificEnvVars() []corev1.EnvVar {
 		envVars = append(envVars, getEnvVariable("TERRAFORM_WORKSPACE", t.Spec.Workspace))
 	}
 
+	envVars = append(envVars, t.getWorkspaceEnvVars()...)
+
+	if t.Status.Stage != "" {
+		envVars = append(envVars, getEnvVariable("TERRAFORM_STAGE", strings.ToLower(string(t.Status.Stage))))
+	}
+
+	envVars = append(envVars, t.getCLIEnvVars()...)
+	envVars = append(envVars, t.getRunOptionsEnvVars()...)
+
 	return envVars
 }
 
@@ -158,6 +219,8 @@ func getInitContainersSpec(t *Terraform) []corev1.Container {
 	containers := []corev1.Container{}
 
 	cpModule := fmt.Sprintf("cp %s/main.tf %s/main.tf", conifgMapModuleMountPath, moduleWorkingDirMountPath)
+	cpLockFile := fmt.Sprintf("if [ -f %[1]s/%[3]s ]; then cp %[1]s/%[3]s %[2]s/%[3]s; fi",
+		conifgMapModuleMountPath, moduleWorkingDirMountPath, lockFileKey)
 
 	commands := []string{
 		"/bin/sh",
@@ -165,7 +228,7 @@ func getInitContainersSpec(t *Terraform) []corev1.Container {
 	}
 
 	args := []string{
-		cpModule,
+		fmt.Sprintf("%s && %s", cpModule, cpLockFile),
 	}
 
 	containers = append(containers, corev1.Container{
@@ -180,7 +243,12 @@ func getInitContainersSpec(t *Terraform) []corev1.Container {
 }
 
 // getJobSpecForRun returns a Kubernetes job spec for the Terraform Runner
-func getJobSpecForRun(t *Terraform, owner metav1.OwnerReference) *batchv1.Job {
+func getJobSpecForRun(t *Terraform, owner metav1.OwnerReference) (*batchv1.Job, error) {
+	image, err := getTerraformRunnerDockerImage(t.GetEngine())
+
+	if err != nil {
+		return nil, err
+	}
 
 	envVars := t.getEnvVariables()
 	volumes := t.getJobVolumes()
@@ -188,9 +256,11 @@ func getJobSpecForRun(t *Terraform, owner metav1.OwnerReference) *batchv1.Job {
 
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      getUniqueResourceName(t.Name, t.Status.RunID),
+			Name:      t.getJobName(),
 			Namespace: t.Namespace,
 			Labels:    getCommonLabels(t.Name, t.Status.RunID),
+			// the run options are kept with the Jobs of the run history
+			Annotations: t.getRunOptionsAnnotations(),
 			OwnerReferences: []metav1.OwnerReference{
 				owner,
 			},
@@ -201,19 +271,22 @@ func getJobSpecForRun(t *Terraform, owner metav1.OwnerReference) *batchv1.Job {
 					Labels: getCommonLabels(t.Name, t.Status.RunID),
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: "terraform-runner",
+					ServiceAccountName: t.GetServiceAccountName(),
 					InitContainers:     getInitContainersSpec(t),
 					Containers: []corev1.Container{
 						{
-							Name:            "terraform",
-							Image:           getTerraformRunnerDockerImage(),
+							Name:            runnerContainerName,
+							Image:           image,
 							VolumeMounts:    mounts,
 							Env:             envVars,
 							ImagePullPolicy: corev1.PullIfNotPresent,
+							// the last lines of the logs are used to classify a failed run
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
 						},
 					},
-					Volumes:       volumes,
-					RestartPolicy: corev1.RestartPolicyNever,
+					Volumes:                       volumes,
+					RestartPolicy:                 corev1.RestartPolicyNever,
+					TerminationGracePeriodSeconds: t.getCancelGracePeriod(),
 				},
 			},
 		},
@@ -221,15 +294,33 @@ func getJobSpecForRun(t *Terraform, owner metav1.OwnerReference) *batchv1.Job {
 
 	job.Spec.BackoffLimit = &t.Spec.RetryLimit
 
-	return job
+	if t.Status.Stage != StagePlan {
+		job.Spec.BackoffLimit = t.getApplyBackoffLimit()
+	}
+
+	// the plan job is kept until the apply job is created from it
+	if t.Status.Stage != StagePlan {
+		job.Spec.TTLSecondsAfterFinished = t.Spec.JobTTLSecondsAfterFinished
+	}
+
+	if t.Spec.Timeout != nil && t.Spec.Timeout.Duration > 0 {
+		deadline := int64(t.Spec.Timeout.Seconds())
+		job.Spec.ActiveDeadlineSeconds = &deadline
+	}
+
+	if err := t.applyRunnerPodTemplate(&job.Spec.Template); err != nil {
+		return nil, err
+	}
+
+	t.addHooks(&job.Spec.Template.Spec)
+
+	return job, nil
 }
 
 // getJobForRun returns the Kubernetes Job of a specific workflow/run
-func getJobForRun(ctx context.Context, runName string, namespace string, runID string) (*batchv1.Job, error) {
+func getJobForRun(ctx context.Context, name string, namespace string) (*batchv1.Job, error) {
 	jobs := kube.ClientSet.BatchV1().Jobs(namespace)
 
-	name := getUniqueResourceName(runName, runID)
-
 	job, err := jobs.Get(ctx, name, metav1.GetOptions{})
 
 	if err != nil {
@@ -239,19 +330,51 @@ func getJobForRun(ctx context.Context, runName string, namespace string, runID s
 	return job, err
 }
 
+// listJobsForRun returns the Jobs of all the runs of a Terraform object
+func listJobsForRun(ctx context.Context, runName string, namespace string) ([]batchv1.Job, error) {
+	list, err := kube.ClientSet.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("terraformRunName=%s", runName),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
 // createJobForRun creates a Kubernetes Job to execute the workflow/run
 func createJobForRun(ctx context.Context, run *Terraform) (*batchv1.Job, error) {
 	jobs := kube.ClientSet.BatchV1().Jobs(run.Namespace)
 
 	ownerRef := run.GetOwnerReference()
 
-	job := getJobSpecForRun(run, ownerRef)
+	job, err := getJobSpecForRun(run, ownerRef)
 
-	if _, err := jobs.Create(ctx, job, metav1.CreateOptions{}); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	return job, nil
+	created, err := jobs.Create(ctx, job, metav1.CreateOptions{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// suspendJobForRun suspends the Kubernetes Job of a specific workflow/run, which terminates its active pods
+func suspendJobForRun(ctx context.Context, name string, namespace string) error {
+	jobs := kube.ClientSet.BatchV1().Jobs(namespace)
+
+	patch := []byte(`{"spec":{"suspend":true}}`)
+
+	if _, err := jobs.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 // deleteJobByRun deletes the Kubernetes Job of the workflow/run
@@ -262,6 +385,15 @@ func deleteJobByRun(ctx context.Context, runName string, namespace string, runID
 
 	deletePolicy := metav1.DeletePropagationForeground
 
+	// the force-unlock job of a cancelled run and the plan job of a run with a separate plan
+	for _, name := range []string{getForceUnlockJobName(runName, runID), getPlanJobName(runName, runID)} {
+		if err := jobs.Delete(ctx, name, metav1.DeleteOptions{
+			PropagationPolicy: &deletePolicy,
+		}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
 	if err := jobs.Delete(ctx, resourceName, metav1.DeleteOptions{
 		PropagationPolicy: &deletePolicy,
 	}); err != nil {
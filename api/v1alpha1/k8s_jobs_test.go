@@ -3,12 +3,14 @@ package v1alpha1
 import (
 	"context"
 	"fmt"
+	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/kuptan/terraform-operator/internal/kube"
+	"github.com/kuptan/terraform-operator/internal/utils"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -60,8 +62,9 @@ var _ = Describe("Kubernetes Jobs", func() {
 		}
 
 		It("returns the job spec and should not be null", func() {
-			jobSpec := getJobSpecForRun(run, ownerRef)
+			jobSpec, err := getJobSpecForRun(run, ownerRef)
 
+			Expect(err).ToNot(HaveOccurred())
 			Expect(jobSpec).ToNot(BeNil())
 
 			job = jobSpec
@@ -83,6 +86,14 @@ var _ = Describe("Kubernetes Jobs", func() {
 			Expect(sshVolume.VolumeSource.Secret.SecretName).To(Equal(run.Spec.GitSSHKey.ValueFrom.Secret.SecretName))
 		})
 
+		It("should use the Terraform engine by default", func() {
+			image, err := getTerraformRunnerDockerImage(EngineTerraform)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(run.GetEngine()).To(Equal(EngineTerraform))
+			Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal(image))
+		})
+
 		It("should contain an environment variable for Terraform workspace", func() {
 			var envVar corev1.EnvVar
 
@@ -148,8 +159,9 @@ var _ = Describe("Kubernetes Jobs", func() {
 		}
 
 		It("should return the job spec", func() {
-			jobSpec := getJobSpecForRun(run, ownerRef)
+			jobSpec, err := getJobSpecForRun(run, ownerRef)
 
+			Expect(err).ToNot(HaveOccurred())
 			Expect(jobSpec).ToNot(BeNil())
 
 			job = jobSpec
@@ -162,4 +174,157 @@ var _ = Describe("Kubernetes Jobs", func() {
 			Expect(created).ToNot(BeNil())
 		})
 	})
+
+	Context("OpenTofu job", func() {
+		run := &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "tofu",
+				Namespace: "default",
+			},
+			Spec: TerraformSpec{
+				Engine:           EngineOpenTofu,
+				TerraformVersion: "1.6.2",
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+			},
+			Status: TerraformStatus{
+				RunID: "12345",
+			},
+		}
+
+		It("should use the OpenTofu runner image if configured", func() {
+			utils.Env.OpenTofuRunnerImage = "kuptan/opentofu-runner"
+			utils.Env.OpenTofuRunnerImageTag = "0.0.1"
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("docker.io/kuptan/opentofu-runner:0.0.1"))
+
+			var engine corev1.EnvVar
+
+			for _, e := range job.Spec.Template.Spec.Containers[0].Env {
+				if e.Name == "TERRAFORM_ENGINE" {
+					engine = e
+					break
+				}
+			}
+
+			Expect(engine.Value).To(Equal(string(EngineOpenTofu)))
+
+			utils.Env.OpenTofuRunnerImage = ""
+			utils.Env.OpenTofuRunnerImageTag = ""
+		})
+
+		It("should refuse the OpenTofu engine without a runner image", func() {
+			Expect(run.ValidateEngine()).ToNot(Succeed())
+
+			utils.Env.OpenTofuRunnerImage = "kuptan/opentofu-runner"
+
+			_, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("OPENTOFU_RUNNER_IMAGE_TAG"))
+
+			utils.Env.OpenTofuRunnerImage = ""
+		})
+	})
+
+	Context("Runner Capabilities", func() {
+		newRun := func() *Terraform {
+			return &Terraform{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "capabilities",
+					Namespace: "default",
+				},
+				Spec: TerraformSpec{
+					TerraformVersion: "1.0.2",
+					Module: Module{
+						Source: "IbraheemAlSaady/test/module",
+					},
+				},
+				Status: TerraformStatus{
+					RunID: "12345",
+				},
+			}
+		}
+
+		AfterEach(func() {
+			utils.Env.RunnerCapabilities = []string{runnerCapabilityStages, runnerCapabilityForceUnlock}
+		})
+
+		It("should accept a single job run with any runner", func() {
+			utils.Env.RunnerCapabilities = nil
+
+			Expect(newRun().ValidateRunnerCapabilities()).To(Succeed())
+		})
+
+		It("should refuse a separate plan if the runner does not support stages", func() {
+			run := newRun()
+			run.Spec.SeparatePlan = true
+
+			Expect(run.ValidateRunnerCapabilities()).To(Succeed())
+
+			utils.Env.RunnerCapabilities = []string{runnerCapabilityForceUnlock}
+
+			err := run.ValidateRunnerCapabilities()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("'stages'"))
+		})
+
+		It("should refuse the force-unlock if the runner does not support it", func() {
+			run := newRun()
+			run.Spec.CancelPolicy = &CancelPolicy{ForceUnlock: true}
+			run.Status.LockID = "abc"
+
+			Expect(run.ValidateRunnerCapabilities()).To(Succeed())
+
+			utils.Env.RunnerCapabilities = []string{runnerCapabilityStages}
+
+			Expect(run.ValidateRunnerCapabilities()).ToNot(Succeed())
+
+			_, err := run.CreateForceUnlockJob(context.Background())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Job TTL and Timeout", func() {
+		run := &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "timeout-run",
+				Namespace: "default",
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+			},
+			Status: TerraformStatus{
+				RunID: "12345",
+			},
+		}
+
+		It("should not set a ttl or deadline by default", func() {
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(job.Spec.TTLSecondsAfterFinished).To(BeNil())
+			Expect(job.Spec.ActiveDeadlineSeconds).To(BeNil())
+		})
+
+		It("should map the ttl and timeout to the job spec", func() {
+			ttl := int32(300)
+
+			timed := run.DeepCopy()
+			timed.Spec.JobTTLSecondsAfterFinished = &ttl
+			timed.Spec.Timeout = &metav1.Duration{Duration: 30 * time.Minute}
+
+			job, err := getJobSpecForRun(timed, timed.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(*job.Spec.TTLSecondsAfterFinished).To(Equal(int32(300)))
+			Expect(*job.Spec.ActiveDeadlineSeconds).To(Equal(int64(1800)))
+		})
+	})
 })
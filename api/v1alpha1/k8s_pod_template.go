@@ -0,0 +1,188 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// reservedRunnerEnvPrefixes holds the prefixes of the environment variables the runner pod template can't
+// set, the operator passes the run to the Terraform Runner with `TERRAFORM_` variables and `TF_CLI_ARGS`
+// would bypass the allowed CLI flags
+var reservedRunnerEnvPrefixes = []string{"TERRAFORM_", "TF_CLI_ARGS"}
+
+// reservedRunnerEnvNames holds the environment variables the operator sets only for some runs
+var reservedRunnerEnvNames = []string{"PLAN_HASH", "PLAN_JSON_PATH", "TF_LOG"}
+
+// isReservedRunnerEnv evaluates if an environment variable of the runner pod template collides with the
+// environment variables set by the operator
+func (t *Terraform) isReservedRunnerEnv(name string) bool {
+	for _, prefix := range reservedRunnerEnvPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	if containsString(reservedRunnerEnvNames, name) {
+		return true
+	}
+
+	for _, env := range t.getRunnerSpecificEnvVars() {
+		if env.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reservedRunnerMountPaths holds the paths the operator mounts the module, the variable files, the git SSH key
+// and the plan at, the runner pod template can't mount a volume at or below them
+var reservedRunnerMountPaths = []string{
+	moduleWorkingDirMountPath,
+	conifgMapModuleMountPath,
+	tfVarsMountPath,
+	gitSSHKeyMountPath,
+	planOutputMountPath,
+}
+
+// isReservedRunnerVolume evaluates if a volume of the runner pod template collides with the volumes set by the operator
+func (t *Terraform) isReservedRunnerVolume(name string) bool {
+	if containsString([]string{emptyDirVolumeName, gitSSHKeyVolumeName, knownHostsVolumeName, planOutputVolume}, name) {
+		return true
+	}
+
+	for _, volume := range t.getJobVolumes() {
+		if volume.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isReservedRunnerMountPath evaluates if a volume mount of the runner pod template would shadow the files mounted
+// by the operator
+func isReservedRunnerMountPath(mountPath string) bool {
+	mountPath = path.Clean(mountPath)
+
+	for _, reserved := range reservedRunnerMountPaths {
+		if mountPath == reserved || strings.HasPrefix(mountPath, reserved+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateRunnerPodTemplate validates that the environment variables, the volumes and the volume mounts of the
+// runner pod template don't override the ones set by the operator
+func (t *Terraform) ValidateRunnerPodTemplate() error {
+	if t.Spec.RunnerPodTemplate == nil {
+		return nil
+	}
+
+	for _, env := range t.Spec.RunnerPodTemplate.Env {
+		if t.isReservedRunnerEnv(env.Name) {
+			return fmt.Errorf("the environment variable '%s' of spec.runnerPodTemplate.env is set by the operator", env.Name)
+		}
+	}
+
+	for _, volume := range t.Spec.RunnerPodTemplate.Volumes {
+		if t.isReservedRunnerVolume(volume.Name) {
+			return fmt.Errorf("the volume '%s' of spec.runnerPodTemplate.volumes is set by the operator", volume.Name)
+		}
+	}
+
+	for _, mount := range t.Spec.RunnerPodTemplate.VolumeMounts {
+		if t.isReservedRunnerVolume(mount.Name) || isReservedRunnerMountPath(mount.MountPath) {
+			return fmt.Errorf("the volume mount '%s' of spec.runnerPodTemplate.volumeMounts is set by the operator", mount.MountPath)
+		}
+	}
+
+	return nil
+}
+
+// getPodSpecPatch returns a pod spec holding the overrides of the runner pod template
+func getPodSpecPatch(tpl *RunnerPodTemplate) corev1.PodSpec {
+	return corev1.PodSpec{
+		NodeSelector:      tpl.NodeSelector,
+		Tolerations:       tpl.Tolerations,
+		Affinity:          tpl.Affinity,
+		PriorityClassName: tpl.PriorityClassName,
+		SecurityContext:   tpl.SecurityContext,
+		ImagePullSecrets:  tpl.ImagePullSecrets,
+		Volumes:           tpl.Volumes,
+		Containers: []corev1.Container{
+			{
+				Name:         runnerContainerName,
+				Resources:    tpl.Resources,
+				VolumeMounts: tpl.VolumeMounts,
+				Env:          tpl.Env,
+			},
+		},
+	}
+}
+
+// mergeStringMaps returns a new map with the values of the overrides, the values of base take precedence
+func mergeStringMaps(overrides map[string]string, base map[string]string) map[string]string {
+	merged := map[string]string{}
+
+	for k, v := range overrides {
+		merged[k] = v
+	}
+
+	for k, v := range base {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// applyRunnerPodTemplate strategically merges the runner pod template overrides onto the generated pod template,
+// the environment variables set by the operator can't be overridden
+func (t *Terraform) applyRunnerPodTemplate(pod *corev1.PodTemplateSpec) error {
+	tpl := t.Spec.RunnerPodTemplate
+
+	if tpl == nil {
+		return nil
+	}
+
+	if err := t.ValidateRunnerPodTemplate(); err != nil {
+		return err
+	}
+
+	original, err := json.Marshal(pod.Spec)
+
+	if err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(getPodSpecPatch(tpl))
+
+	if err != nil {
+		return err
+	}
+
+	merged, err := strategicpatch.StrategicMergePatch(original, patch, corev1.PodSpec{})
+
+	if err != nil {
+		return err
+	}
+
+	spec := corev1.PodSpec{}
+
+	if err := json.Unmarshal(merged, &spec); err != nil {
+		return err
+	}
+
+	pod.Spec = spec
+	pod.ObjectMeta.Labels = mergeStringMaps(tpl.Metadata.Labels, pod.ObjectMeta.Labels)
+	pod.ObjectMeta.Annotations = mergeStringMaps(tpl.Metadata.Annotations, pod.ObjectMeta.Annotations)
+
+	return nil
+}
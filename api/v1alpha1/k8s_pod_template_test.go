@@ -0,0 +1,188 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Runner Pod Template", func() {
+	Context("Pod Template Overrides", func() {
+		run := &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod-template",
+				Namespace: "default",
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Module: Module{
+					Source:  "IbraheemAlSaady/test/module",
+					Version: "0.0.1",
+				},
+				Variables: []Variable{
+					{
+						Key:   "length",
+						Value: "16",
+					},
+				},
+				RunnerPodTemplate: &RunnerPodTemplate{
+					Metadata: RunnerPodMetadata{
+						Labels: map[string]string{
+							"team":             "platform",
+							"terraformRunName": "override",
+						},
+						Annotations: map[string]string{
+							"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/terraform",
+						},
+					},
+					Resources: corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{
+							corev1.ResourceMemory: resource.MustParse("512Mi"),
+						},
+					},
+					NodeSelector: map[string]string{
+						"pool": "terraform",
+					},
+					Tolerations: []corev1.Toleration{
+						{
+							Key:      "dedicated",
+							Operator: corev1.TolerationOpExists,
+						},
+					},
+					PriorityClassName: "high",
+					ImagePullSecrets: []corev1.LocalObjectReference{
+						{
+							Name: "registry",
+						},
+					},
+					Volumes: []corev1.Volume{
+						getEmptyDirVolume("cache"),
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						getVolumeMountSpec("cache", "/root/.terraform.d/plugin-cache", false),
+					},
+					Env: []corev1.EnvVar{
+						getEnvVariable("TF_PLUGIN_CACHE_DIR", "/root/.terraform.d/plugin-cache"),
+						getEnvVariable("TF_VAR_length", "32"),
+					},
+				},
+			},
+			Status: TerraformStatus{
+				RunID: "12345",
+			},
+		}
+
+		It("should merge the overrides onto the runner pod", func() {
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+
+			Expect(err).ToNot(HaveOccurred())
+
+			pod := job.Spec.Template
+			container := pod.Spec.Containers[0]
+
+			Expect(pod.Labels["team"]).To(Equal("platform"))
+			Expect(pod.Labels["terraformRunName"]).To(Equal(run.Name))
+			Expect(pod.Annotations).To(HaveKey("eks.amazonaws.com/role-arn"))
+
+			Expect(pod.Spec.NodeSelector["pool"]).To(Equal("terraform"))
+			Expect(pod.Spec.Tolerations).To(HaveLen(1))
+			Expect(pod.Spec.PriorityClassName).To(Equal("high"))
+			Expect(pod.Spec.ImagePullSecrets).To(HaveLen(1))
+			Expect(pod.Spec.InitContainers).To(HaveLen(1))
+
+			Expect(container.Name).To(Equal(runnerContainerName))
+			image, err := getTerraformRunnerDockerImage(EngineTerraform)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.Image).To(Equal(image))
+			Expect(container.Resources.Limits.Memory().String()).To(Equal("512Mi"))
+
+			volumes := map[string]bool{}
+
+			for _, v := range pod.Spec.Volumes {
+				volumes[v.Name] = true
+			}
+
+			Expect(volumes).To(HaveKey("cache"))
+			Expect(volumes).To(HaveKey(emptyDirVolumeName))
+
+			mounts := map[string]bool{}
+
+			for _, m := range container.VolumeMounts {
+				mounts[m.MountPath] = true
+			}
+
+			Expect(mounts).To(HaveKey("/root/.terraform.d/plugin-cache"))
+			Expect(mounts).To(HaveKey(moduleWorkingDirMountPath))
+
+			env := map[string]string{}
+
+			for _, e := range container.Env {
+				env[e.Name] = e.Value
+			}
+
+			Expect(env["TF_PLUGIN_CACHE_DIR"]).To(Equal("/root/.terraform.d/plugin-cache"))
+			Expect(env["TF_VAR_length"]).To(Equal("32"))
+			Expect(env).To(HaveKey("TERRAFORM_VERSION"))
+		})
+
+		It("should refuse to override the environment variables set by the operator", func() {
+			tpl := run.Spec.RunnerPodTemplate.DeepCopy()
+
+			for _, name := range []string{"TERRAFORM_DESTROY", "OUTPUT_SECRET_NAME", "TERRAFORM_APPLY_ARGS", "TF_CLI_ARGS_apply", "PLAN_HASH"} {
+				run.Spec.RunnerPodTemplate.Env = []corev1.EnvVar{getEnvVariable(name, "override")}
+
+				Expect(run.ValidateRunnerPodTemplate()).ToNot(Succeed())
+
+				_, err := getJobSpecForRun(run, run.GetOwnerReference())
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(name))
+			}
+
+			run.Spec.RunnerPodTemplate = tpl
+
+			Expect(run.ValidateRunnerPodTemplate()).To(Succeed())
+		})
+
+		It("should refuse to override the volumes and mounts set by the operator", func() {
+			tpl := run.Spec.RunnerPodTemplate.DeepCopy()
+
+			for _, name := range []string{emptyDirVolumeName, "pod-template-12345"} {
+				run.Spec.RunnerPodTemplate.Volumes = []corev1.Volume{getEmptyDirVolume(name)}
+
+				_, err := getJobSpecForRun(run, run.GetOwnerReference())
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(name))
+			}
+
+			run.Spec.RunnerPodTemplate.Volumes = tpl.Volumes
+
+			for _, mountPath := range []string{moduleWorkingDirMountPath, conifgMapModuleMountPath, "/tmp/tfmodule/main.tf", "/terraform/modules/"} {
+				run.Spec.RunnerPodTemplate.VolumeMounts = []corev1.VolumeMount{getVolumeMountSpec("cache", mountPath, false)}
+
+				_, err := getJobSpecForRun(run, run.GetOwnerReference())
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(mountPath))
+			}
+
+			run.Spec.RunnerPodTemplate.VolumeMounts = []corev1.VolumeMount{getVolumeMountSpec("cache", "/tmp/tfmodule-cache", false)}
+
+			Expect(run.ValidateRunnerPodTemplate()).To(Succeed())
+
+			run.Spec.RunnerPodTemplate = tpl
+		})
+
+		It("should not change the pod if no overrides are defined", func() {
+			run.Spec.RunnerPodTemplate = nil
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(job.Spec.Template.Spec.NodeSelector).To(BeEmpty())
+			Expect(job.Spec.Template.Annotations).To(BeEmpty())
+		})
+	})
+})
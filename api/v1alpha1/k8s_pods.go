@@ -0,0 +1,63 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// listPodsForRun returns the pods of a specific workflow/run
+func listPodsForRun(ctx context.Context, runName string, namespace string, runID string) ([]corev1.Pod, error) {
+	list, err := kube.ClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("terraformRunName=%s,terraformRunId=%s", runName, runID),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return list.Items, nil
+}
+
+// getTerminationMessage returns the termination message of the latest failed Terraform Runner container
+func getTerminationMessage(pods []corev1.Pod) string {
+	var latest *corev1.ContainerStateTerminated
+
+	for _, pod := range pods {
+		// the runner is an init container of a plan pod with post-plan hooks
+		statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+
+		for _, status := range statuses {
+			terminated := status.State.Terminated
+
+			if status.Name != runnerContainerName || terminated == nil || terminated.ExitCode == 0 {
+				continue
+			}
+
+			if latest == nil || latest.FinishedAt.Before(&terminated.FinishedAt) {
+				latest = terminated
+			}
+		}
+	}
+
+	if latest == nil {
+		return ""
+	}
+
+	return latest.Message
+}
+
+// GetFailureMessage returns the termination message of the failed Terraform Runner, which holds
+// the last lines of its logs
+func (t *Terraform) GetFailureMessage(ctx context.Context) (string, error) {
+	pods, err := listPodsForRun(ctx, t.Name, t.Namespace, t.Status.RunID)
+
+	if err != nil {
+		return "", err
+	}
+
+	return getTerminationMessage(pods), nil
+}
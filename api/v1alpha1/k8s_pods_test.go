@@ -0,0 +1,83 @@
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Kubernetes Pods", func() {
+	run := &Terraform{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pods-run",
+			Namespace: "default",
+		},
+		Status: TerraformStatus{
+			RunID: "abc123",
+		},
+	}
+
+	newPod := func(name string, exitCode int32, message string, finishedAt time.Time) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: run.Namespace,
+				Labels:    getCommonLabels(run.Name, run.Status.RunID),
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: runnerContainerName,
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{
+								ExitCode:   exitCode,
+								Message:    message,
+								FinishedAt: metav1.NewTime(finishedAt),
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	Context("Failure Message", func() {
+		It("should return an empty message when there are no pods", func() {
+			message, err := run.GetFailureMessage(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message).To(BeEmpty())
+		})
+
+		It("should return the termination message of the latest failed runner", func() {
+			now := time.Now()
+
+			pods := []*corev1.Pod{
+				newPod("pods-run-1", 1, "Error: first attempt", now.Add(-time.Minute)),
+				newPod("pods-run-2", 1, "Error acquiring the state lock", now),
+			}
+
+			for _, pod := range pods {
+				_, err := kube.ClientSet.CoreV1().Pods(run.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			message, err := run.GetFailureMessage(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message).To(Equal("Error acquiring the state lock"))
+		})
+
+		It("should ignore successful containers", func() {
+			pods := []corev1.Pod{*newPod("pods-run-3", 0, "Apply complete!", time.Now())}
+
+			Expect(getTerminationMessage(pods)).To(BeEmpty())
+		})
+	})
+})
@@ -2,20 +2,49 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
+	"sort"
 
 	"github.com/kuptan/terraform-operator/internal/kube"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// getRbacLabels returns the labels that mark the RBAC of the Terraform Runner as owned by the operator
+func getRbacLabels() map[string]string {
+	return map[string]string{
+		"component":                    runnerRBACName,
+		"owner":                        "run.terraform-operator.io",
+		"app.kubernetes.io/managed-by": "terraform-operator",
+	}
+}
+
+// hasRbacLabels evaluates if the labels of the object contain the RBAC labels
+func hasRbacLabels(labels map[string]string) bool {
+	for k, v := range getRbacLabels() {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// setRbacLabels adds the RBAC labels to the object while keeping its other labels
+func setRbacLabels(meta *metav1.ObjectMeta) {
+	meta.Labels = mergeStringMaps(getRbacLabels(), meta.Labels)
+}
+
 // createServiceAccount creates a Kubernetes ServiceAccount for the Terraform Runner
 func createServiceAccount(ctx context.Context, name string, namespace string) (*corev1.ServiceAccount, error) {
 	key := &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: namespace,
+			Labels:    getRbacLabels(),
 		},
 	}
 
@@ -28,26 +57,268 @@ func createServiceAccount(ctx context.Context, name string, namespace string) (*
 	return sa, nil
 }
 
-// createRoleBinding creates a Kubernetes RoleBinding for the Terraform Runner
-func createRoleBinding(ctx context.Context, name string, namespace string) (*rbacv1.RoleBinding, error) {
-	key := &rbacv1.RoleBinding{
+// runnerResources holds the names of the resources the Terraform Runner is allowed to access, ListSecrets
+// is set if the Terraform Runner lists the workspaces of the Kubernetes backend, and DeletableSecrets holds
+// the state secrets of the workspaces the Terraform Runner deletes
+type runnerResources struct {
+	Secrets          []string
+	ConfigMaps       []string
+	Leases           []string
+	DeletableSecrets []string
+	ListSecrets      bool
+}
+
+// add appends the resources names that do not exist yet
+func (r *runnerResources) add(other runnerResources) {
+	r.Secrets = appendUnique(r.Secrets, other.Secrets...)
+	r.ConfigMaps = appendUnique(r.ConfigMaps, other.ConfigMaps...)
+	r.Leases = appendUnique(r.Leases, other.Leases...)
+	r.DeletableSecrets = appendUnique(r.DeletableSecrets, other.DeletableSecrets...)
+	r.ListSecrets = r.ListSecrets || other.ListSecrets
+}
+
+// isEmpty evaluates if there are no resources
+func (r *runnerResources) isEmpty() bool {
+	return len(r.Secrets) == 0 && len(r.ConfigMaps) == 0 && len(r.Leases) == 0 && len(r.DeletableSecrets) == 0
+}
+
+// appendUnique appends the values that do not exist in the slice and keeps it sorted
+func appendUnique(slice []string, values ...string) []string {
+	for _, v := range values {
+		if !containsString(slice, v) {
+			slice = append(slice, v)
+		}
+	}
+
+	sort.Strings(slice)
+
+	return slice
+}
+
+// getRoleRules returns the least privilege rules of the Terraform Runner. Kubernetes does not support
+// restricting `create` and `list` by resource names, the Kubernetes backend needs `create` to create the
+// state secret and the lock lease on the first run. `list` returns every secret of the namespace, it is only
+// granted if a Terraform object uses the Kubernetes backend, which lists its workspaces on `terraform init`
+func getRoleRules(resources runnerResources) []rbacv1.PolicyRule {
+	secretVerbs := []string{"create"}
+
+	if resources.ListSecrets {
+		secretVerbs = append(secretVerbs, "list")
+	}
+
+	rules := []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{""},
+			Resources: []string{"secrets"},
+			Verbs:     secretVerbs,
+		},
+		{
+			APIGroups: []string{"coordination.k8s.io"},
+			Resources: []string{"leases"},
+			Verbs:     []string{"create"},
+		},
+	}
+
+	if len(resources.Secrets) > 0 {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			ResourceNames: resources.Secrets,
+			Verbs:         []string{"get", "update", "patch"},
+		})
+	}
+
+	if len(resources.DeletableSecrets) > 0 {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			ResourceNames: resources.DeletableSecrets,
+			Verbs:         []string{"delete"},
+		})
+	}
+
+	if len(resources.ConfigMaps) > 0 {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups:     []string{""},
+			Resources:     []string{"configmaps"},
+			ResourceNames: resources.ConfigMaps,
+			Verbs:         []string{"get", "update", "patch"},
+		})
+	}
+
+	if len(resources.Leases) > 0 {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups:     []string{"coordination.k8s.io"},
+			Resources:     []string{"leases"},
+			ResourceNames: resources.Leases,
+			Verbs:         []string{"get", "update", "delete"},
+		})
+	}
+
+	return rules
+}
+
+// getResourcesFromRole returns the resource names allowed by the Role of the Terraform Runner
+func getResourcesFromRole(role *rbacv1.Role) runnerResources {
+	resources := runnerResources{}
+
+	for _, rule := range role.Rules {
+		if len(rule.ResourceNames) == 0 && containsString(rule.Resources, "secrets") && containsString(rule.Verbs, "list") {
+			resources.ListSecrets = true
+		}
+
+		if len(rule.ResourceNames) == 0 || len(rule.Resources) == 0 {
+			continue
+		}
+
+		switch rule.Resources[0] {
+		case "secrets":
+			if containsString(rule.Verbs, "delete") {
+				resources.DeletableSecrets = appendUnique(resources.DeletableSecrets, rule.ResourceNames...)
+				continue
+			}
+
+			resources.Secrets = appendUnique(resources.Secrets, rule.ResourceNames...)
+		case "configmaps":
+			resources.ConfigMaps = appendUnique(resources.ConfigMaps, rule.ResourceNames...)
+		case "leases":
+			resources.Leases = appendUnique(resources.Leases, rule.ResourceNames...)
+		}
+	}
+
+	return resources
+}
+
+// reconcileServiceAccount creates the ServiceAccount of the Terraform Runner if not exist, and restores
+// its labels if they drifted. Returns true if the ServiceAccount was repaired
+func reconcileServiceAccount(ctx context.Context, name string, namespace string) (bool, error) {
+	serviceAccounts := kube.ClientSet.CoreV1().ServiceAccounts(namespace)
+
+	existing, err := serviceAccounts.Get(ctx, name, metav1.GetOptions{})
+
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+
+		if _, err := createServiceAccount(ctx, name, namespace); err != nil {
+			return false, err
+		}
+
+		return false, nil
+	}
+
+	if hasRbacLabels(existing.Labels) {
+		return false, nil
+	}
+
+	setRbacLabels(&existing.ObjectMeta)
+
+	if _, err := serviceAccounts.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// getRole returns the Role of the Terraform Runner, nil is returned if the Role does not exist
+func getRole(ctx context.Context, name string, namespace string) (*rbacv1.Role, error) {
+	role, err := kube.ClientSet.RbacV1().Roles(namespace).Get(ctx, name, metav1.GetOptions{})
+
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// reconcileRole creates the Role of the Terraform Runner, if the Role already exists the resource names
+// will be added to it. If replace is set, the resource names of the Role will be replaced instead.
+// Returns true if the rules or the labels of the Role drifted and were repaired
+func reconcileRole(ctx context.Context, name string, namespace string, resources runnerResources, replace bool) (bool, error) {
+	roles := kube.ClientSet.RbacV1().Roles(namespace)
+
+	existing, err := getRole(ctx, name, namespace)
+
+	if err != nil {
+		return false, err
+	}
+
+	if existing == nil {
+		key := &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    getRbacLabels(),
+			},
+			Rules: getRoleRules(resources),
+		}
+
+		if _, err := roles.Create(ctx, key, metav1.CreateOptions{}); err != nil {
+			return false, err
+		}
+
+		return false, nil
+	}
+
+	current := getResourcesFromRole(existing)
+
+	// rules that cannot be rebuilt from their own resource names were edited outside of the operator
+	drifted := !hasRbacLabels(existing.Labels) || !equality.Semantic.DeepEqual(existing.Rules, getRoleRules(current))
+
+	desired := resources
+
+	if !replace {
+		desired = current
+		desired.add(resources)
+	}
+
+	rules := getRoleRules(desired)
+
+	if !drifted && equality.Semantic.DeepEqual(existing.Rules, rules) {
+		return false, nil
+	}
+
+	setRbacLabels(&existing.ObjectMeta)
+	existing.Rules = rules
+
+	if _, err := roles.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return false, err
+	}
+
+	return drifted, nil
+}
+
+// getRoleBindingSpec returns the desired RoleBinding of the Terraform Runner
+func getRoleBindingSpec(name string, namespace string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: namespace,
+			Labels:    getRbacLabels(),
 		},
 		RoleRef: rbacv1.RoleRef{
-			Kind:     "ClusterRole",
+			Kind:     "Role",
 			Name:     name,
 			APIGroup: "rbac.authorization.k8s.io",
 		},
 		Subjects: []rbacv1.Subject{
-			rbacv1.Subject{
+			{
 				Kind:      "ServiceAccount",
 				Name:      name,
 				Namespace: namespace,
 			},
 		},
 	}
+}
+
+// createRoleBinding creates a Kubernetes RoleBinding for the Terraform Runner
+func createRoleBinding(ctx context.Context, name string, namespace string) (*rbacv1.RoleBinding, error) {
+	key := getRoleBindingSpec(name, namespace)
 
 	role, err := kube.ClientSet.RbacV1().RoleBindings(namespace).Create(ctx, key, metav1.CreateOptions{})
 
@@ -58,6 +329,53 @@ func createRoleBinding(ctx context.Context, name string, namespace string) (*rba
 	return role, nil
 }
 
+// reconcileRoleBinding creates the RoleBinding of the Terraform Runner if not exist, and repairs its
+// subjects, roleRef and labels if they drifted. The roleRef of a RoleBinding is immutable, so a RoleBinding
+// with a drifted roleRef is recreated. Returns true if the RoleBinding was repaired
+func reconcileRoleBinding(ctx context.Context, name string, namespace string) (bool, error) {
+	roleBindings := kube.ClientSet.RbacV1().RoleBindings(namespace)
+	desired := getRoleBindingSpec(name, namespace)
+
+	existing, err := roleBindings.Get(ctx, name, metav1.GetOptions{})
+
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+
+		if _, err := createRoleBinding(ctx, name, namespace); err != nil {
+			return false, err
+		}
+
+		return false, nil
+	}
+
+	if existing.RoleRef != desired.RoleRef {
+		if err := roleBindings.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+
+		if _, err := createRoleBinding(ctx, name, namespace); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	if hasRbacLabels(existing.Labels) && equality.Semantic.DeepEqual(existing.Subjects, desired.Subjects) {
+		return false, nil
+	}
+
+	setRbacLabels(&existing.ObjectMeta)
+	existing.Subjects = desired.Subjects
+
+	if _, err := roleBindings.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 // isServiceAccountExist checks whether the ServiceAccount for the Terraform Runner exist
 func isServiceAccountExist(ctx context.Context, name string, namespace string) (bool, error) {
 	_, err := kube.ClientSet.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
@@ -88,31 +406,128 @@ func isRoleBindingExist(ctx context.Context, name string, namespace string) (boo
 	return true, nil
 }
 
-// createRbacConfigIfNotExist validates if RBAC exist for the Terraform Runner and creates it if not exist
-func createRbacConfigIfNotExist(ctx context.Context, name string, namespace string) error {
+// isRbacConfigExist checks if the ServiceAccount, Role and RoleBinding of the Terraform Runner exist
+func isRbacConfigExist(ctx context.Context, name string, namespace string) (bool, error) {
 	saExist, err := isServiceAccountExist(ctx, name, namespace)
 
+	if err != nil || !saExist {
+		return false, err
+	}
+
+	role, err := getRole(ctx, name, namespace)
+
+	if err != nil || role == nil {
+		return false, err
+	}
+
+	return isRoleBindingExist(ctx, name, namespace)
+}
+
+// reconcileRbacConfig creates the RBAC of the Terraform Runner if not exist and repairs the objects that drifted
+// from their desired state, the resource names are added to the Role of the Terraform Runner or replace its
+// resource names if replace is set. Returns the objects that were repaired.
+//
+// The RBAC is shared by the Terraform objects of the namespace, so it has no owner reference: the garbage
+// collector would delete it with its owner while other Terraform objects still use it. The labels mark the
+// objects as managed by the operator, which deletes them once no Terraform object uses them anymore
+func reconcileRbacConfig(ctx context.Context, name string, namespace string, resources runnerResources, replace bool) ([]string, error) {
+	repaired := []string{}
+
+	saRepaired, err := reconcileServiceAccount(ctx, name, namespace)
+
+	if err != nil {
+		return repaired, err
+	}
+
+	if saRepaired {
+		repaired = append(repaired, fmt.Sprintf("ServiceAccount/%s", name))
+	}
+
+	roleRepaired, err := reconcileRole(ctx, name, namespace, resources, replace)
+
+	if err != nil {
+		return repaired, err
+	}
+
+	if roleRepaired {
+		repaired = append(repaired, fmt.Sprintf("Role/%s", name))
+	}
+
+	roleBindingRepaired, err := reconcileRoleBinding(ctx, name, namespace)
+
 	if err != nil {
+		return repaired, err
+	}
+
+	if roleBindingRepaired {
+		repaired = append(repaired, fmt.Sprintf("RoleBinding/%s", name))
+	}
+
+	return repaired, nil
+}
+
+// deleteRbacConfig deletes the ServiceAccount, Role and RoleBinding of the Terraform Runner, objects without
+// the RBAC labels were not created by the operator and are kept
+func deleteRbacConfig(ctx context.Context, name string, namespace string) error {
+	roleBindings := kube.ClientSet.RbacV1().RoleBindings(namespace)
+
+	roleBinding, err := roleBindings.Get(ctx, name, metav1.GetOptions{})
+
+	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
 
-	roleBindingExist, err := isRoleBindingExist(ctx, name, namespace)
+	if err == nil && hasRbacLabels(roleBinding.Labels) {
+		if err := roleBindings.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	role, err := getRole(ctx, name, namespace)
 
 	if err != nil {
 		return err
 	}
 
-	if !saExist {
-		if _, err := createServiceAccount(ctx, name, namespace); err != nil {
+	if role != nil && hasRbacLabels(role.Labels) {
+		if err := kube.ClientSet.RbacV1().Roles(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
 			return err
 		}
 	}
 
-	if !roleBindingExist {
-		if _, err := createRoleBinding(ctx, name, namespace); err != nil {
+	serviceAccounts := kube.ClientSet.CoreV1().ServiceAccounts(namespace)
+
+	sa, err := serviceAccounts.Get(ctx, name, metav1.GetOptions{})
+
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	if err == nil && hasRbacLabels(sa.Labels) {
+		if err := serviceAccounts.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// SyncRunnerRBAC keeps the RBAC of the Terraform Runner in sync with the Terraform objects of a namespace.
+// The Role is scoped to the resources of the Terraform objects that use the default service account, and
+// the RBAC is deleted once no Terraform object in the namespace uses it anymore. Returns the RBAC objects
+// that drifted from their desired state and were repaired
+func SyncRunnerRBAC(ctx context.Context, namespace string, runs []Terraform) ([]string, error) {
+	resources := runnerResources{}
+
+	for i := range runs {
+		if runs[i].usesDefaultServiceAccount() {
+			resources.add(runs[i].getRunnerResources())
+		}
+	}
+
+	if resources.isEmpty() {
+		return []string{}, deleteRbacConfig(ctx, runnerRBACName, namespace)
+	}
+
+	return reconcileRbacConfig(ctx, runnerRBACName, namespace, resources, true)
+}
@@ -3,6 +3,8 @@ package v1alpha1
 import (
 	"context"
 
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/kuptan/terraform-operator/internal/kube"
@@ -38,8 +40,11 @@ var _ = Describe("Kubernetes RBAC", func() {
 			Expect(found).To(BeFalse())
 		})
 
-		It("should create service account and role binding", func() {
-			err := createRbacConfigIfNotExist(context.Background(), rbacName, namespace)
+		It("should create service account, role and role binding", func() {
+			repaired, err := reconcileRbacConfig(context.Background(), rbacName, namespace, runnerResources{
+				Secrets: []string{"outputs-secret"},
+			}, false)
+			Expect(repaired).To(BeEmpty())
 			Expect(err).ToNot(HaveOccurred())
 
 			sa, err := kube.ClientSet.CoreV1().ServiceAccounts(namespace).Get(context.Background(), rbacName, metav1.GetOptions{})
@@ -51,6 +56,318 @@ var _ = Describe("Kubernetes RBAC", func() {
 
 			Expect(err).ToNot(HaveOccurred())
 			Expect(roleBinding.Name).To(Equal(rbacName))
+			Expect(roleBinding.RoleRef.Kind).To(Equal("Role"))
+			Expect(roleBinding.RoleRef.Name).To(Equal(rbacName))
+
+			role, err := kube.ClientSet.RbacV1().Roles(namespace).Get(context.Background(), rbacName, metav1.GetOptions{})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(getResourcesFromRole(role).Secrets).To(ConsistOf("outputs-secret"))
+		})
+
+		It("should add the resource names to the existing role", func() {
+			repaired, err := reconcileRbacConfig(context.Background(), rbacName, namespace, runnerResources{
+				Secrets:    []string{"another-secret"},
+				ConfigMaps: []string{"lock-configmap"},
+			}, false)
+			Expect(repaired).To(BeEmpty())
+			Expect(err).ToNot(HaveOccurred())
+
+			role, err := kube.ClientSet.RbacV1().Roles(namespace).Get(context.Background(), rbacName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			resources := getResourcesFromRole(role)
+
+			Expect(resources.Secrets).To(ConsistOf("outputs-secret", "another-secret"))
+			Expect(resources.ConfigMaps).To(ConsistOf("lock-configmap"))
+			Expect(resources.Leases).To(BeEmpty())
+		})
+
+		It("should not grant unscoped access to existing resources", func() {
+			rules := getRoleRules(runnerResources{Secrets: []string{"outputs-secret"}})
+
+			for _, rule := range rules {
+				if len(rule.ResourceNames) == 0 {
+					Expect(rule.Verbs).To(Equal([]string{"create"}), "unscoped verbs for %v", rule.Resources)
+				}
+			}
+		})
+
+		It("should only allow listing secrets for the Kubernetes backend", func() {
+			run := &Terraform{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "rbac-list",
+					Namespace: namespace,
+				},
+			}
+
+			resources := run.getRunnerResources()
+
+			Expect(resources.ListSecrets).To(BeTrue())
+			Expect(getRoleRules(resources)[0].Verbs).To(Equal([]string{"create", "list"}))
+			Expect(getResourcesFromRole(&rbacv1.Role{Rules: getRoleRules(resources)}).ListSecrets).To(BeTrue())
+
+			run.Spec.Backend = `backend "s3" {}`
+
+			Expect(run.getRunnerResources().ListSecrets).To(BeFalse())
+		})
+	})
+
+	Context("RBAC Drift", func() {
+		driftNamespace := "rbac-drift"
+		resources := runnerResources{Secrets: []string{"outputs-secret"}}
+
+		It("should create the rbac with the operator labels", func() {
+			_, err := reconcileRbacConfig(context.Background(), rbacName, driftNamespace, resources, true)
+			Expect(err).ToNot(HaveOccurred())
+
+			roleBinding, err := kube.ClientSet.RbacV1().RoleBindings(driftNamespace).Get(context.Background(), rbacName, metav1.GetOptions{})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hasRbacLabels(roleBinding.Labels)).To(BeTrue())
+		})
+
+		It("should not report a repair when nothing drifted", func() {
+			repaired, err := reconcileRbacConfig(context.Background(), rbacName, driftNamespace, resources, true)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(repaired).To(BeEmpty())
+		})
+
+		It("should repair the subjects of the role binding", func() {
+			roleBindings := kube.ClientSet.RbacV1().RoleBindings(driftNamespace)
+
+			roleBinding, err := roleBindings.Get(context.Background(), rbacName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			roleBinding.Subjects[0].Name = "someone-else"
+
+			_, err = roleBindings.Update(context.Background(), roleBinding, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			repaired, err := reconcileRbacConfig(context.Background(), rbacName, driftNamespace, resources, true)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(repaired).To(ConsistOf("RoleBinding/" + rbacName))
+
+			roleBinding, err = roleBindings.Get(context.Background(), rbacName, metav1.GetOptions{})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(roleBinding.Subjects[0].Name).To(Equal(rbacName))
+		})
+
+		It("should recreate the role binding when the roleRef drifted", func() {
+			roleBindings := kube.ClientSet.RbacV1().RoleBindings(driftNamespace)
+
+			err := roleBindings.Delete(context.Background(), rbacName, metav1.DeleteOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			drifted := getRoleBindingSpec(rbacName, driftNamespace)
+			drifted.RoleRef.Kind = "ClusterRole"
+
+			_, err = roleBindings.Create(context.Background(), drifted, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			repaired, err := reconcileRbacConfig(context.Background(), rbacName, driftNamespace, resources, true)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(repaired).To(ConsistOf("RoleBinding/" + rbacName))
+
+			roleBinding, err := roleBindings.Get(context.Background(), rbacName, metav1.GetOptions{})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(roleBinding.RoleRef.Kind).To(Equal("Role"))
+		})
+
+		It("should repair the rules and labels of the role", func() {
+			roles := kube.ClientSet.RbacV1().Roles(driftNamespace)
+
+			role, err := roles.Get(context.Background(), rbacName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			role.Labels = nil
+			role.Rules[0].Verbs = []string{"*"}
+
+			_, err = roles.Update(context.Background(), role, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			repaired, err := reconcileRbacConfig(context.Background(), rbacName, driftNamespace, resources, true)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(repaired).To(ConsistOf("Role/" + rbacName))
+
+			role, err = roles.Get(context.Background(), rbacName, metav1.GetOptions{})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(role.Rules).To(Equal(getRoleRules(resources)))
+			Expect(hasRbacLabels(role.Labels)).To(BeTrue())
+		})
+	})
+
+	Context("Sync Runner RBAC", func() {
+		syncNamespace := "rbac-sync"
+
+		run := Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "sync-run",
+				Namespace: syncNamespace,
+			},
+			Spec: TerraformSpec{
+				Workspace: "dev",
+			},
+		}
+
+		It("should scope the role to the resources of the runs", func() {
+			_, err := SyncRunnerRBAC(context.Background(), syncNamespace, []Terraform{run})
+			Expect(err).ToNot(HaveOccurred())
+
+			role, err := kube.ClientSet.RbacV1().Roles(syncNamespace).Get(context.Background(), runnerRBACName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			resources := getResourcesFromRole(role)
+
+			Expect(resources.Secrets).To(ConsistOf("sync-run-outputs", "tfstate-dev-sync-run"))
+			Expect(resources.ConfigMaps).To(ConsistOf("sync-run-lock", "sync-run-run-state"))
+			Expect(resources.Leases).To(ConsistOf("lock-tfstate-dev-sync-run"))
+		})
+
+		It("should grant access to the plan chunks of the current run only", func() {
+			planned := run.DeepCopy()
+			planned.Spec.Backend = `backend "s3" {}`
+			planned.Status.RunID = "abc123"
+
+			_, err := SyncRunnerRBAC(context.Background(), syncNamespace, []Terraform{*planned})
+			Expect(err).ToNot(HaveOccurred())
+
+			role, err := kube.ClientSet.RbacV1().Roles(syncNamespace).Get(context.Background(), runnerRBACName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			secrets := getResourcesFromRole(role).Secrets
+
+			Expect(secrets).To(HaveLen(maxPlanChunks + 1))
+			Expect(secrets).To(ContainElements("sync-run-outputs", "sync-run-abc123-plan-0", "sync-run-abc123-plan-7"))
+
+			planned.Status.RunID = "def456"
+
+			_, err = SyncRunnerRBAC(context.Background(), syncNamespace, []Terraform{*planned})
+			Expect(err).ToNot(HaveOccurred())
+
+			role, err = kube.ClientSet.RbacV1().Roles(syncNamespace).Get(context.Background(), runnerRBACName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			secrets = getResourcesFromRole(role).Secrets
+
+			Expect(secrets).To(ContainElement("sync-run-def456-plan-0"))
+			Expect(secrets).ToNot(ContainElement("sync-run-abc123-plan-0"))
+		})
+
+		It("should allow deleting the workspace of a destroyed run", func() {
+			destroyed := run.DeepCopy()
+			destroyed.Spec.Destroy = true
+			destroyed.Spec.DeleteWorkspaceOnDestroy = true
+
+			_, err := SyncRunnerRBAC(context.Background(), syncNamespace, []Terraform{*destroyed})
+			Expect(err).ToNot(HaveOccurred())
+
+			role, err := kube.ClientSet.RbacV1().Roles(syncNamespace).Get(context.Background(), runnerRBACName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			resources := getResourcesFromRole(role)
+
+			Expect(resources.Secrets).To(ConsistOf("sync-run-outputs", "tfstate-dev-sync-run", "tfstate-default-sync-run"))
+			Expect(resources.Leases).To(ConsistOf("lock-tfstate-dev-sync-run", "lock-tfstate-default-sync-run"))
+			Expect(resources.DeletableSecrets).To(ConsistOf("tfstate-dev-sync-run"))
+			Expect(role.Rules).To(ContainElement(rbacv1.PolicyRule{
+				APIGroups:     []string{""},
+				Resources:     []string{"secrets"},
+				ResourceNames: []string{"tfstate-dev-sync-run"},
+				Verbs:         []string{"delete"},
+			}))
+			Expect(role.Rules).To(Equal(getRoleRules(resources)))
+
+			_, err = SyncRunnerRBAC(context.Background(), syncNamespace, []Terraform{run})
+			Expect(err).ToNot(HaveOccurred())
+
+			role, err = kube.ClientSet.RbacV1().Roles(syncNamespace).Get(context.Background(), runnerRBACName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(getResourcesFromRole(role).DeletableSecrets).To(BeEmpty())
+		})
+
+		It("should not grant access to the state of a custom backend", func() {
+			custom := run.DeepCopy()
+			custom.Spec.Backend = `backend "local" {}`
+
+			_, err := SyncRunnerRBAC(context.Background(), syncNamespace, []Terraform{*custom})
+			Expect(err).ToNot(HaveOccurred())
+
+			role, err := kube.ClientSet.RbacV1().Roles(syncNamespace).Get(context.Background(), runnerRBACName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			resources := getResourcesFromRole(role)
+
+			Expect(resources.Secrets).To(ConsistOf("sync-run-outputs"))
+			Expect(resources.Leases).To(BeEmpty())
+		})
+
+		It("should not reconcile the synced rbac again when creating a run", func() {
+			_, err := SyncRunnerRBAC(context.Background(), syncNamespace, []Terraform{run})
+			Expect(err).ToNot(HaveOccurred())
+
+			other := run.DeepCopy()
+			other.Name = "sync-other"
+
+			Expect(other.ensureServiceAccount(context.Background(), syncNamespace)).To(Succeed())
+
+			role, err := getRole(context.Background(), runnerRBACName, syncNamespace)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(getResourcesFromRole(role).Secrets).ToNot(ContainElement("sync-other-outputs"))
+		})
+
+		It("should delete the rbac when no run uses the default service account", func() {
+			custom := run.DeepCopy()
+			custom.Spec.ServiceAccountName = "custom-sa"
+
+			_, err := SyncRunnerRBAC(context.Background(), syncNamespace, []Terraform{*custom})
+			Expect(err).ToNot(HaveOccurred())
+
+			found, err := isServiceAccountExist(context.Background(), runnerRBACName, syncNamespace)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+
+			found, err = isRoleBindingExist(context.Background(), runnerRBACName, syncNamespace)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+
+			role, err := getRole(context.Background(), runnerRBACName, syncNamespace)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(role).To(BeNil())
+		})
+
+		It("should not delete rbac that was not created by the operator", func() {
+			manualNamespace := "rbac-manual"
+
+			_, err := kube.ClientSet.CoreV1().ServiceAccounts(manualNamespace).Create(context.Background(), &corev1.ServiceAccount{
+				ObjectMeta: metav1.ObjectMeta{Name: runnerRBACName, Namespace: manualNamespace},
+			}, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = kube.ClientSet.RbacV1().Roles(manualNamespace).Create(context.Background(), &rbacv1.Role{
+				ObjectMeta: metav1.ObjectMeta{Name: runnerRBACName, Namespace: manualNamespace},
+			}, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			roleBinding := getRoleBindingSpec(runnerRBACName, manualNamespace)
+			roleBinding.Labels = nil
+
+			_, err = kube.ClientSet.RbacV1().RoleBindings(manualNamespace).Create(context.Background(), roleBinding, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = SyncRunnerRBAC(context.Background(), manualNamespace, []Terraform{})
+			Expect(err).ToNot(HaveOccurred())
+
+			exist, err := isRbacConfigExist(context.Background(), runnerRBACName, manualNamespace)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(exist).To(BeTrue())
 		})
 	})
 })
@@ -2,6 +2,8 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 
 	"github.com/kuptan/terraform-operator/internal/kube"
 	corev1 "k8s.io/api/core/v1"
@@ -61,3 +63,85 @@ func createSecretForOutputs(ctx context.Context, namespacedName types.Namespaced
 
 	return secret, nil
 }
+
+// getPlanChunks returns the chunk Secrets of the plan of a run in order, nil is returned if the plan was not saved.
+// The number of chunks is read from the plan-chunks annotation of the first chunk
+func getPlanChunks(ctx context.Context, name string, namespace string, runID string) ([]corev1.Secret, error) {
+	first, err := isSecretExist(ctx, getPlanChunkSecretName(name, runID, 0), namespace)
+
+	if err != nil || first == nil {
+		return nil, err
+	}
+
+	count := 1
+
+	if value, ok := first.Annotations[PlanChunksAnnotation]; ok {
+		count, err = strconv.Atoi(value)
+
+		if err != nil || count < 1 || count > maxPlanChunks {
+			return nil, fmt.Errorf("invalid plan chunks annotation '%s' on secret '%s', a plan is saved in at most %d chunks", value, first.Name, maxPlanChunks)
+		}
+	}
+
+	chunks := []corev1.Secret{*first}
+
+	for i := 1; i < count; i++ {
+		chunkName := getPlanChunkSecretName(name, runID, i)
+
+		chunk, err := isSecretExist(ctx, chunkName, namespace)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if chunk == nil {
+			return nil, fmt.Errorf("plan chunk secret '%s' was not found", chunkName)
+		}
+
+		chunks = append(chunks, *chunk)
+	}
+
+	return chunks, nil
+}
+
+// adoptPlanChunks sets the owner reference, the labels of the run and the hash of the inputs the plan was made
+// with on the chunk Secrets of the plan, the chunks are created by the Terraform Runner
+func adoptPlanChunks(ctx context.Context, chunks []corev1.Secret, t *Terraform) error {
+	secrets := kube.ClientSet.CoreV1().Secrets(t.Namespace)
+
+	for i := range chunks {
+		chunk := &chunks[i]
+
+		if len(chunk.OwnerReferences) > 0 {
+			continue
+		}
+
+		chunk.OwnerReferences = []metav1.OwnerReference{t.GetOwnerReference()}
+		chunk.Labels = mergeStringMaps(chunk.Labels, getCommonLabels(t.Name, t.Status.RunID))
+
+		if t.Status.PlanHash != "" {
+			chunk.Annotations = mergeStringMaps(chunk.Annotations, map[string]string{PlanHashAnnotation: t.Status.PlanHash})
+		}
+
+		if _, err := secrets.Update(ctx, chunk, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deletePlanByRun deletes the chunk Secrets of the plan of a run
+func deletePlanByRun(ctx context.Context, name string, namespace string, runID string) error {
+	secrets := kube.ClientSet.CoreV1().Secrets(namespace)
+
+	for i := 0; ; i++ {
+		if err := secrets.Delete(ctx, getPlanChunkSecretName(name, runID, i), metav1.DeleteOptions{}); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
@@ -66,6 +66,7 @@ var _ = BeforeSuite(func() {
 	os.Setenv("DOCKER_REGISTRY", "docker.io")
 	os.Setenv("TERRAFORM_RUNNER_IMAGE", "ibraheemalsaady/terraform-runner")
 	os.Setenv("TERRAFORM_RUNNER_IMAGE_TAG", "0.0.3")
+	os.Setenv("TERRAFORM_RUNNER_CAPABILITIES", "stages,force-unlock")
 	os.Setenv("KNOWN_HOSTS_CONFIGMAP_NAME", "operator-known-hosts")
 
 	err := SchemeBuilder.AddToScheme(scheme.Scheme)
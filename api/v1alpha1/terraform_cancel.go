@@ -0,0 +1,173 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// lockIDKey is the key of the run state ConfigMap the Terraform Runner records the ID of the state lock it
+// acquired in
+const lockIDKey = "lockId"
+
+// GetCancelRequestedAt returns the value of the cancel-requested-at annotation
+func (t *Terraform) GetCancelRequestedAt() string {
+	return t.GetAnnotations()[CancelRequestedAtAnnotation]
+}
+
+// IsCancelRequested evaluates if cancelling the run in progress was requested through the cancel-requested-at annotation
+func (t *Terraform) IsCancelRequested() bool {
+	cancelAt := t.GetCancelRequestedAt()
+
+	return cancelAt != "" && cancelAt != t.Status.LastHandledCancelAt
+}
+
+// IsCancelling evaluates if the workflow/run is being cancelled
+func (t *Terraform) IsCancelling() bool {
+	return t.Status.RunStatus == RunCancelling
+}
+
+// getCancelGracePeriod returns the grace period of the Terraform Runner, nil is returned to use the pod default
+func (t *Terraform) getCancelGracePeriod() *int64 {
+	if t.Spec.CancelPolicy == nil {
+		return nil
+	}
+
+	return t.Spec.CancelPolicy.GracePeriodSeconds
+}
+
+// CancelRun suspends the Job of the run, Kubernetes terminates its pods with SIGTERM and
+// kills them once the grace period is over
+func (t *Terraform) CancelRun(ctx context.Context) error {
+	if err := suspendJobForRun(ctx, t.getJobName(), t.Namespace); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// IsRunnerTerminated evaluates if all the pods of the run have exited
+func (t *Terraform) IsRunnerTerminated(ctx context.Context) (bool, error) {
+	pods, err := listPodsForRun(ctx, t.Name, t.Namespace, t.Status.RunID)
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// getAcquiredLockID returns the ID of the state lock the Terraform Runner recorded after acquiring it, the
+// run state ConfigMap is reset on every run so the lock belongs to the current run
+func (t *Terraform) getAcquiredLockID(ctx context.Context) (string, error) {
+	lockID, _, err := getConfigMapKey(ctx, getRunStateConfigMapName(t.Name), t.Namespace, lockIDKey)
+
+	return lockID, err
+}
+
+// GetStateLockID returns the ID of the lock the run left on the state. Only the lock the Terraform Runner
+// recorded after acquiring it is returned, a lock held by someone else is never reported. For the default
+// Kubernetes backend the lock is only returned if the lease of the state is still held with it
+func (t *Terraform) GetStateLockID(ctx context.Context) (string, error) {
+	lockID, err := t.getAcquiredLockID(ctx)
+
+	if err != nil || lockID == "" {
+		return "", err
+	}
+
+	if !t.usesDefaultBackend() {
+		// terraform force-unlock refuses a lock ID that does not match the lock held on the state
+		return lockID, nil
+	}
+
+	leaseName := getStateLockLeaseName(getStateSecretName(t.Name, t.getWorkspace()))
+
+	lease, err := kube.ClientSet.CoordinationV1().Leases(t.Namespace).Get(ctx, leaseName, metav1.GetOptions{})
+
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != lockID {
+		return "", nil
+	}
+
+	return lockID, nil
+}
+
+// getForceUnlockJobName returns the name of the force-unlock Job of a run
+func getForceUnlockJobName(name string, runID string) string {
+	return getUniqueResourceName(name, runID) + "-unlock"
+}
+
+// CreateForceUnlockJob creates a Job that runs `terraform force-unlock` with the recorded lock ID,
+// the Job reuses the module of the cancelled run
+func (t *Terraform) CreateForceUnlockJob(ctx context.Context) (*batchv1.Job, error) {
+	if !hasRunnerCapability(runnerCapabilityForceUnlock) {
+		return nil, fmt.Errorf("the terraform runner does not support the '%s' capability", runnerCapabilityForceUnlock)
+	}
+
+	// the force-unlock job is not a stage of the run, only the pre-init hooks run before it
+	run := t.DeepCopy()
+	run.Status.Stage = ""
+
+	if run.Spec.Hooks != nil {
+		run.Spec.Hooks = &Hooks{PreInit: run.Spec.Hooks.PreInit}
+	}
+
+	job, err := getJobSpecForRun(run, t.GetOwnerReference())
+
+	if err != nil {
+		return nil, err
+	}
+
+	var noRetries int32 = 0
+
+	job.Name = getForceUnlockJobName(t.Name, t.Status.RunID)
+	job.Spec.BackoffLimit = &noRetries
+	job.Spec.ActiveDeadlineSeconds = nil
+	// the job is kept until the controller reported its result
+	job.Spec.TTLSecondsAfterFinished = nil
+
+	for i, c := range job.Spec.Template.Spec.Containers {
+		if c.Name == runnerContainerName {
+			job.Spec.Template.Spec.Containers[i].Env = append(c.Env, getEnvVariable("TERRAFORM_FORCE_UNLOCK_ID", t.Status.LockID))
+		}
+	}
+
+	if _, err := kube.ClientSet.BatchV1().Jobs(t.Namespace).Create(ctx, job, metav1.CreateOptions{}); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// GetForceUnlockJob returns the force-unlock Job of the run, nil is returned if it was not created
+func (t *Terraform) GetForceUnlockJob(ctx context.Context) (*batchv1.Job, error) {
+	job, err := getJobForRun(ctx, getForceUnlockJobName(t.Name, t.Status.RunID), t.Namespace)
+
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return job, nil
+}
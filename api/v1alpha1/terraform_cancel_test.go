@@ -0,0 +1,227 @@
+package v1alpha1
+
+import (
+	"context"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform Cancel", func() {
+	newRun := func() *Terraform {
+		return &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cancel-run",
+				Namespace: "default",
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+			},
+			Status: TerraformStatus{
+				RunID:     "abc123",
+				RunStatus: RunRunning,
+			},
+		}
+	}
+
+	Context("Cancel Request", func() {
+		It("should detect a cancel request through the cancel-requested-at annotation", func() {
+			run := newRun()
+
+			Expect(run.IsCancelRequested()).To(BeFalse())
+
+			run.SetAnnotations(map[string]string{
+				CancelRequestedAtAnnotation: "2022-08-01T10:00:00Z",
+			})
+
+			Expect(run.IsCancelRequested()).To(BeTrue())
+
+			run.Status.LastHandledCancelAt = run.GetCancelRequestedAt()
+
+			Expect(run.IsCancelRequested()).To(BeFalse())
+		})
+
+		It("should set the grace period on the runner pod", func() {
+			run := newRun()
+			grace := int64(120)
+			run.Spec.CancelPolicy = &CancelPolicy{GracePeriodSeconds: &grace}
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(*job.Spec.Template.Spec.TerminationGracePeriodSeconds).To(Equal(int64(120)))
+		})
+	})
+
+	Context("Cancel Run", func() {
+		It("should suspend the job of the run", func() {
+			run := newRun()
+
+			_, err := createJobForRun(context.Background(), run)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(run.CancelRun(context.Background())).ToNot(HaveOccurred())
+
+			job, err := run.GetJobByRun(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(job.Spec.Suspend).ToNot(BeNil())
+			Expect(*job.Spec.Suspend).To(BeTrue())
+		})
+
+		It("should not fail if the job does not exist", func() {
+			run := newRun()
+
+			Expect(run.CancelRun(context.Background())).ToNot(HaveOccurred())
+		})
+
+		It("should wait for the runner pods to exit", func() {
+			run := newRun()
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cancel-run-pod",
+					Namespace: run.Namespace,
+					Labels:    getCommonLabels(run.Name, run.Status.RunID),
+				},
+				Status: corev1.PodStatus{
+					Phase: corev1.PodRunning,
+				},
+			}
+
+			pods := kube.ClientSet.CoreV1().Pods(run.Namespace)
+
+			_, err := pods.Create(context.Background(), pod, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			terminated, err := run.IsRunnerTerminated(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(terminated).To(BeFalse())
+
+			pod.Status.Phase = corev1.PodFailed
+
+			_, err = pods.Update(context.Background(), pod, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			terminated, err = run.IsRunnerTerminated(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(terminated).To(BeTrue())
+		})
+	})
+
+	Context("State Lock", func() {
+		recordLockID := func(run *Terraform, lockID string) {
+			_, err := createConfigMapForRunState(context.Background(), types.NamespacedName{Name: run.Name, Namespace: run.Namespace}, run)
+			Expect(err).ToNot(HaveOccurred())
+
+			configMaps := kube.ClientSet.CoreV1().ConfigMaps(run.Namespace)
+
+			cm, err := configMaps.Get(context.Background(), getRunStateConfigMapName(run.Name), metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			cm.Data[lockIDKey] = lockID
+
+			_, err = configMaps.Update(context.Background(), cm, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		It("should only report the lock the run acquired on a custom backend", func() {
+			run := newRun()
+			run.Name = "cancel-custom"
+			run.Spec.Backend = `backend "s3" {}`
+
+			lockID, err := run.GetStateLockID(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lockID).To(BeEmpty())
+
+			recordLockID(run, "9db590f1-b6fe-c5f2-2678-8804f089deba")
+
+			lockID, err = run.GetStateLockID(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lockID).To(Equal("9db590f1-b6fe-c5f2-2678-8804f089deba"))
+
+			_, err = createConfigMapForRunState(context.Background(), types.NamespacedName{Name: run.Name, Namespace: run.Namespace}, run)
+			Expect(err).ToNot(HaveOccurred())
+
+			lockID, err = run.GetStateLockID(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lockID).To(BeEmpty(), "the lock of the previous run is reset")
+		})
+
+		It("should pass the run state configmap to the terraform runner", func() {
+			run := newRun()
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(getEnvVariable("RUN_STATE_CONFIGMAP_NAME", "cancel-run-run-state")))
+		})
+
+		It("should only report the lease of the default backend if the run holds it", func() {
+			run := newRun()
+
+			holder := "9db590f1-b6fe-c5f2-2678-8804f089deba"
+
+			lease := &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "lock-tfstate-default-cancel-run",
+					Namespace: run.Namespace,
+				},
+				Spec: coordinationv1.LeaseSpec{
+					HolderIdentity: &holder,
+				},
+			}
+
+			_, err := kube.ClientSet.CoordinationV1().Leases(run.Namespace).Create(context.Background(), lease, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			lockID, err := run.GetStateLockID(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lockID).To(BeEmpty(), "the lease is held by someone else")
+
+			recordLockID(run, "another-lock")
+
+			lockID, err = run.GetStateLockID(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lockID).To(BeEmpty())
+
+			recordLockID(run, holder)
+
+			lockID, err = run.GetStateLockID(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lockID).To(Equal(holder))
+		})
+
+		It("should create a force-unlock job with the lock ID", func() {
+			run := newRun()
+			run.Status.LockID = "9db590f1"
+
+			job, err := run.CreateForceUnlockJob(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(job.Name).To(Equal("cancel-run-abc123-unlock"))
+
+			var unlockID corev1.EnvVar
+
+			for _, e := range job.Spec.Template.Spec.Containers[0].Env {
+				if e.Name == "TERRAFORM_FORCE_UNLOCK_ID" {
+					unlockID = e
+				}
+			}
+
+			Expect(unlockID.Value).To(Equal("9db590f1"))
+
+			_, err = kube.ClientSet.BatchV1().Jobs(run.Namespace).Get(context.Background(), job.Name, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+})
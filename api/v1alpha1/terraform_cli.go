@@ -0,0 +1,131 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// allowedCLIFlags holds the flags that can be passed as extra arguments of each Terraform command. Flags that
+// conflict with the spec or with how the Terraform Runner drives Terraform (e.g. `-var`, `-target`, `-destroy`,
+// `-backend-config`, `-lock`) are not allowed, nor flags that write files no one collects from the runner pod
+// (e.g. `-generate-config-out`)
+var allowedCLIFlags = map[string]map[string]bool{
+	"init": {
+		"upgrade":     true,
+		"reconfigure": true,
+		"get":         true,
+		"lockfile":    true,
+		"no-color":    true,
+	},
+	"plan": {
+		"compact-warnings": true,
+		"no-color":         true,
+	},
+	"apply": {
+		"compact-warnings": true,
+		"no-color":         true,
+	},
+}
+
+// getCLIFlagName returns the name of the flag of an argument, e.g. `upgrade` for `-upgrade=true`
+func getCLIFlagName(arg string) string {
+	name := strings.TrimLeft(arg, "-")
+
+	if i := strings.Index(name, "="); i >= 0 {
+		name = name[:i]
+	}
+
+	return name
+}
+
+// validateCLIArgs validates the extra arguments of a Terraform command against the allowed flags
+func validateCLIArgs(command string, args []string) error {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("invalid %s argument '%s', only flags are allowed", command, arg)
+		}
+
+		if !allowedCLIFlags[command][getCLIFlagName(arg)] {
+			return fmt.Errorf("the %s flag '%s' is not allowed", command, arg)
+		}
+	}
+
+	return nil
+}
+
+// ValidateCLIOptions validates the extra arguments of the Terraform commands in spec.cli
+func (t *Terraform) ValidateCLIOptions() error {
+	cli := t.Spec.CLI
+
+	if cli == nil {
+		return nil
+	}
+
+	commands := []struct {
+		name string
+		args []string
+	}{
+		{name: "init", args: cli.InitArgs},
+		{name: "plan", args: cli.PlanArgs},
+		{name: "apply", args: cli.ApplyArgs},
+	}
+
+	for _, command := range commands {
+		if err := validateCLIArgs(command.name, command.args); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getCLIArgsEnvVar returns the extra arguments of a Terraform command as a JSON list environment variable
+func getCLIArgsEnvVar(name string, args []string) corev1.EnvVar {
+	data, _ := json.Marshal(args)
+
+	return getEnvVariable(name, string(data))
+}
+
+// getCLIEnvVars returns the environment variables of the Terraform CLI options for the Terraform Runner
+func (t *Terraform) getCLIEnvVars() []corev1.EnvVar {
+	envVars := []corev1.EnvVar{}
+	cli := t.Spec.CLI
+
+	if cli == nil {
+		return envVars
+	}
+
+	if cli.Parallelism != nil {
+		envVars = append(envVars, getEnvVariable("TERRAFORM_PARALLELISM", strconv.Itoa(int(*cli.Parallelism))))
+	}
+
+	if cli.LockTimeout != nil {
+		envVars = append(envVars, getEnvVariable("TERRAFORM_LOCK_TIMEOUT", cli.LockTimeout.Duration.String()))
+	}
+
+	if cli.Refresh != nil {
+		envVars = append(envVars, getEnvVariable("TERRAFORM_REFRESH", strconv.FormatBool(*cli.Refresh)))
+	}
+
+	if len(cli.InitArgs) > 0 {
+		envVars = append(envVars, getCLIArgsEnvVar("TERRAFORM_INIT_ARGS", cli.InitArgs))
+	}
+
+	if len(cli.PlanArgs) > 0 {
+		envVars = append(envVars, getCLIArgsEnvVar("TERRAFORM_PLAN_ARGS", cli.PlanArgs))
+	}
+
+	if len(cli.ApplyArgs) > 0 {
+		envVars = append(envVars, getCLIArgsEnvVar("TERRAFORM_APPLY_ARGS", cli.ApplyArgs))
+	}
+
+	if cli.LogLevel != "" {
+		envVars = append(envVars, getEnvVariable("TF_LOG", cli.LogLevel))
+	}
+
+	return envVars
+}
@@ -0,0 +1,82 @@
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform CLI", func() {
+	newRun := func(cli *CLIOptions) *Terraform {
+		return &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cli-run",
+				Namespace: "default",
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+				CLI: cli,
+			},
+			Status: TerraformStatus{
+				RunID: "abc123",
+			},
+		}
+	}
+
+	Context("CLI Options", func() {
+		It("should accept the allowed flags", func() {
+			run := newRun(&CLIOptions{
+				InitArgs:  []string{"-upgrade", "--lockfile=readonly"},
+				PlanArgs:  []string{"-compact-warnings", "-no-color"},
+				ApplyArgs: []string{"-no-color"},
+			})
+
+			Expect(run.ValidateCLIOptions()).To(Succeed())
+			Expect(newRun(nil).ValidateCLIOptions()).To(Succeed())
+		})
+
+		It("should refuse the flags that are not allowed", func() {
+			Expect(newRun(&CLIOptions{PlanArgs: []string{"-target=aws_instance.web"}}).ValidateCLIOptions()).ToNot(Succeed())
+			Expect(newRun(&CLIOptions{InitArgs: []string{"-backend-config=bucket=other"}}).ValidateCLIOptions()).ToNot(Succeed())
+			Expect(newRun(&CLIOptions{ApplyArgs: []string{"-lock=false"}}).ValidateCLIOptions()).ToNot(Succeed())
+			Expect(newRun(&CLIOptions{PlanArgs: []string{"main.tf"}}).ValidateCLIOptions()).ToNot(Succeed())
+			Expect(newRun(&CLIOptions{PlanArgs: []string{"-generate-config-out=generated.tf"}}).ValidateCLIOptions()).ToNot(Succeed())
+		})
+
+		It("should pass the options to the terraform runner", func() {
+			parallelism := int32(4)
+			refresh := false
+
+			run := newRun(&CLIOptions{
+				Parallelism: &parallelism,
+				LockTimeout: &metav1.Duration{Duration: 90 * time.Second},
+				Refresh:     &refresh,
+				InitArgs:    []string{"-upgrade"},
+				PlanArgs:    []string{"-compact-warnings", "-no-color"},
+				LogLevel:    "DEBUG",
+			})
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			env := job.Spec.Template.Spec.Containers[0].Env
+
+			Expect(env).To(ContainElement(getEnvVariable("TERRAFORM_PARALLELISM", "4")))
+			Expect(env).To(ContainElement(getEnvVariable("TERRAFORM_LOCK_TIMEOUT", "1m30s")))
+			Expect(env).To(ContainElement(getEnvVariable("TERRAFORM_REFRESH", "false")))
+			Expect(env).To(ContainElement(getEnvVariable("TERRAFORM_INIT_ARGS", `["-upgrade"]`)))
+			Expect(env).To(ContainElement(getEnvVariable("TERRAFORM_PLAN_ARGS", `["-compact-warnings","-no-color"]`)))
+			Expect(env).To(ContainElement(getEnvVariable("TF_LOG", "DEBUG")))
+
+			for _, e := range env {
+				Expect(e.Name).ToNot(Equal("TERRAFORM_APPLY_ARGS"))
+			}
+		})
+	})
+})
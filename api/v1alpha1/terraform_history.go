@@ -0,0 +1,75 @@
+package v1alpha1
+
+import (
+	"sort"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// getHistoryLimit returns the number of successful and failed runs to keep
+func (t *Terraform) getHistoryLimit() (int32, int32) {
+	if t.Spec.HistoryLimit == nil {
+		return 0, 0
+	}
+
+	return t.Spec.HistoryLimit.Successful, t.Spec.HistoryLimit.Failed
+}
+
+// getExpiredRunIDs returns the IDs of the previous runs whose Jobs and ConfigMaps are beyond the history limit.
+// The current run is always kept, runs that did not succeed count as failed, and a run that only
+// left its module ConfigMap behind is always expired
+func (t *Terraform) getExpiredRunIDs(jobs []batchv1.Job, configMaps []corev1.ConfigMap) []string {
+	successfulLimit, failedLimit := t.getHistoryLimit()
+
+	expired := []string{}
+	seen := map[string]bool{}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[j].CreationTimestamp.Before(&jobs[i].CreationTimestamp)
+	})
+
+	for _, job := range jobs {
+		runID := job.Labels["terraformRunId"]
+
+		if runID == "" || seen[runID] {
+			continue
+		}
+
+		seen[runID] = true
+
+		if runID == t.Status.RunID {
+			continue
+		}
+
+		if job.Status.Succeeded > 0 {
+			if successfulLimit > 0 {
+				successfulLimit--
+				continue
+			}
+		} else if failedLimit > 0 {
+			failedLimit--
+			continue
+		}
+
+		expired = append(expired, runID)
+	}
+
+	for _, cm := range configMaps {
+		runID := cm.Labels["terraformRunId"]
+
+		// the lock file ConfigMap carries the labels of the run that captured it
+		if runID == "" || cm.Name != getUniqueResourceName(t.Name, runID) {
+			continue
+		}
+
+		if seen[runID] || runID == t.Status.RunID {
+			continue
+		}
+
+		seen[runID] = true
+		expired = append(expired, runID)
+	}
+
+	return expired
+}
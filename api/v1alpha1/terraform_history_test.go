@@ -0,0 +1,102 @@
+package v1alpha1
+
+import (
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform History", func() {
+	name := "history"
+	now := time.Now()
+
+	newJob := func(runID string, age time.Duration, succeeded bool) batchv1.Job {
+		job := batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              getUniqueResourceName(name, runID),
+				Labels:            getCommonLabels(name, runID),
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+		}
+
+		if succeeded {
+			job.Status.Succeeded = 1
+		} else {
+			job.Status.Failed = 1
+		}
+
+		return job
+	}
+
+	newConfigMap := func(cmName string, runID string) corev1.ConfigMap {
+		return corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   cmName,
+				Labels: getCommonLabels(name, runID),
+			},
+		}
+	}
+
+	jobs := func() []batchv1.Job {
+		return []batchv1.Job{
+			newJob("current", 0, false),
+			newJob("success1", time.Minute, true),
+			newJob("failed1", 2*time.Minute, false),
+			newJob("success2", 3*time.Minute, true),
+			newJob("failed2", 4*time.Minute, false),
+		}
+	}
+
+	Context("Expired Runs", func() {
+		It("should expire all previous runs by default", func() {
+			run := &Terraform{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Status:     TerraformStatus{RunID: "current"},
+			}
+
+			expired := run.getExpiredRunIDs(jobs(), []corev1.ConfigMap{})
+
+			Expect(expired).To(ConsistOf("success1", "failed1", "success2", "failed2"))
+		})
+
+		It("should keep the most recent runs within the history limit", func() {
+			run := &Terraform{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec: TerraformSpec{
+					HistoryLimit: &HistoryLimit{Successful: 1, Failed: 1},
+				},
+				Status: TerraformStatus{RunID: "current"},
+			}
+
+			expired := run.getExpiredRunIDs(jobs(), []corev1.ConfigMap{})
+
+			Expect(expired).To(ConsistOf("success2", "failed2"))
+		})
+
+		It("should expire the configmaps of runs without a job", func() {
+			run := &Terraform{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec: TerraformSpec{
+					HistoryLimit: &HistoryLimit{Successful: 5, Failed: 5},
+				},
+				Status: TerraformStatus{RunID: "current"},
+			}
+
+			configMaps := []corev1.ConfigMap{
+				newConfigMap(getUniqueResourceName(name, "current"), "current"),
+				newConfigMap(getUniqueResourceName(name, "success1"), "success1"),
+				newConfigMap(getUniqueResourceName(name, "orphan"), "orphan"),
+				newConfigMap(getLockFileConfigMapName(name), "success1"),
+			}
+
+			expired := run.getExpiredRunIDs(jobs(), configMaps)
+
+			Expect(expired).To(ConsistOf("orphan"))
+		})
+	})
+})
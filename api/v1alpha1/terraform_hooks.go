@@ -0,0 +1,345 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	planOutputMountPath string = "/tmp/plan"
+	planOutputVolume    string = "plan"
+
+	preInitHookPrefix     string = "pre-init"
+	postPlanHookPrefix    string = "post-plan"
+	preApplyHookPrefix    string = "pre-apply"
+	postApplyHookPrefix   string = "post-apply"
+	postDestroyHookPrefix string = "post-destroy"
+)
+
+// hookPrefixes holds the prefixes of the hook containers of every point of the run lifecycle
+var hookPrefixes = []string{
+	preInitHookPrefix,
+	postPlanHookPrefix,
+	preApplyHookPrefix,
+	postApplyHookPrefix,
+	postDestroyHookPrefix,
+}
+
+// getPlanJSONPath returns the path the Terraform Runner writes the plan JSON to for the post-plan hooks
+func getPlanJSONPath() string {
+	return fmt.Sprintf("%s/%s", planOutputMountPath, planJSONKey)
+}
+
+// getHookContainerName returns the name of the container of a hook at a point of the run lifecycle
+func getHookContainerName(prefix string, name string) string {
+	return fmt.Sprintf("%s-%s", prefix, name)
+}
+
+// isHookContainer evaluates if a container runs a hook
+func isHookContainer(name string) bool {
+	for _, prefix := range hookPrefixes {
+		if strings.HasPrefix(name, prefix+"-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getHooks returns the hooks of the run
+func (t *Terraform) getHooks() Hooks {
+	if t.Spec.Hooks == nil {
+		return Hooks{}
+	}
+
+	return *t.Spec.Hooks
+}
+
+// getPostPlanHooks returns the post-plan hooks of the run
+func (t *Terraform) getPostPlanHooks() []Hook {
+	return t.getHooks().PostPlan
+}
+
+// getPostRunHooks returns the prefix and the hooks that run after a successful apply or destroy
+func (t *Terraform) getPostRunHooks() (string, []Hook) {
+	if t.Spec.Destroy {
+		return postDestroyHookPrefix, t.getHooks().PostDestroy
+	}
+
+	return postApplyHookPrefix, t.getHooks().PostApply
+}
+
+// getApplyBackoffLimit returns the backoff limit of a Job that applies or destroys, the Job is not retried if
+// it has post-apply or post-destroy hooks since a failed hook would re-run the apply or the destroy in a new pod
+func (t *Terraform) getApplyBackoffLimit() *int32 {
+	limit := t.Spec.RetryLimit
+
+	if _, hooks := t.getPostRunHooks(); len(hooks) > 0 {
+		limit = 0
+	}
+
+	return &limit
+}
+
+// getHookContainers returns the containers of the hooks, the hooks run with the module working directory
+// mounted
+func getHookContainers(prefix string, hooks []Hook, mounts []corev1.VolumeMount, env []corev1.EnvVar) []corev1.Container {
+	containers := []corev1.Container{}
+
+	mounts = append([]corev1.VolumeMount{getVolumeMountSpec(emptyDirVolumeName, moduleWorkingDirMountPath, false)}, mounts...)
+	env = append([]corev1.EnvVar{getEnvVariable("TERRAFORM_WORKING_DIR", moduleWorkingDirMountPath)}, env...)
+
+	for _, hook := range hooks {
+		containers = append(containers, corev1.Container{
+			Name:            getHookContainerName(prefix, hook.Name),
+			Image:           hook.Image,
+			Command:         hook.Command,
+			Args:            hook.Args,
+			Env:             append(append([]corev1.EnvVar{}, env...), hook.Env...),
+			VolumeMounts:    mounts,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			// the last lines of the logs are used as the message of a failed hook
+			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+		})
+	}
+
+	return containers
+}
+
+// getRunnerContainer returns the Terraform Runner container of a pod, the runner is an init container
+// of a pod with hooks that run after it
+func getRunnerContainer(spec *corev1.PodSpec) *corev1.Container {
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == runnerContainerName {
+			return &spec.Containers[i]
+		}
+	}
+
+	for i := range spec.InitContainers {
+		if spec.InitContainers[i].Name == runnerContainerName {
+			return &spec.InitContainers[i]
+		}
+	}
+
+	return nil
+}
+
+// runHooksAfterRunner runs the hook containers once the Terraform Runner succeeded, the runner becomes the
+// last init container of the pod and the hooks become its containers
+func runHooksAfterRunner(spec *corev1.PodSpec, hooks []corev1.Container) {
+	if len(hooks) == 0 {
+		return
+	}
+
+	spec.InitContainers = append(spec.InitContainers, *getRunnerContainer(spec))
+	spec.Containers = hooks
+}
+
+// addHooks adds the hooks of the current stage to the pod of a run. The pre-init hooks run after the module
+// is copied to the working directory, the post-plan hooks run after the plan Job and the post-apply or the
+// post-destroy hooks run after a Job that applies
+func (t *Terraform) addHooks(spec *corev1.PodSpec) {
+	hooks := t.getHooks()
+
+	spec.InitContainers = append(spec.InitContainers, getHookContainers(preInitHookPrefix, hooks.PreInit, nil, nil)...)
+
+	if t.Status.Stage == StagePlan {
+		t.addPostPlanHooks(spec)
+		return
+	}
+
+	prefix, postRunHooks := t.getPostRunHooks()
+
+	runHooksAfterRunner(spec, getHookContainers(prefix, postRunHooks, nil, nil))
+}
+
+// addPostPlanHooks runs the post-plan hooks in the pod of the plan Job with the plan JSON the Terraform Runner
+// writes to the shared plan volume
+func (t *Terraform) addPostPlanHooks(spec *corev1.PodSpec) {
+	hooks := t.getPostPlanHooks()
+
+	if len(hooks) == 0 {
+		return
+	}
+
+	env := getEnvVariable("PLAN_JSON_PATH", getPlanJSONPath())
+	runner := getRunnerContainer(spec)
+
+	runner.Env = append(runner.Env, env)
+	runner.VolumeMounts = append(runner.VolumeMounts, getVolumeMountSpec(planOutputVolume, planOutputMountPath, false))
+
+	spec.Volumes = append(spec.Volumes, getEmptyDirVolume(planOutputVolume))
+
+	mounts := []corev1.VolumeMount{getVolumeMountSpec(planOutputVolume, planOutputMountPath, true)}
+
+	runHooksAfterRunner(spec, getHookContainers(postPlanHookPrefix, hooks, mounts, []corev1.EnvVar{env}))
+}
+
+// removePostPlanHooks restores the Terraform Runner as the container of a pod copied from the plan Job
+func removePostPlanHooks(spec *corev1.PodSpec) {
+	initContainers := []corev1.Container{}
+	var runner *corev1.Container
+
+	for i, c := range spec.InitContainers {
+		if c.Name == runnerContainerName {
+			runner = &spec.InitContainers[i]
+			continue
+		}
+
+		initContainers = append(initContainers, c)
+	}
+
+	if runner == nil {
+		return
+	}
+
+	env := []corev1.EnvVar{}
+
+	for _, e := range runner.Env {
+		if e.Name != "PLAN_JSON_PATH" {
+			env = append(env, e)
+		}
+	}
+
+	mounts := []corev1.VolumeMount{}
+
+	for _, m := range runner.VolumeMounts {
+		if m.Name != planOutputVolume {
+			mounts = append(mounts, m)
+		}
+	}
+
+	volumes := []corev1.Volume{}
+
+	for _, v := range spec.Volumes {
+		if v.Name != planOutputVolume {
+			volumes = append(volumes, v)
+		}
+	}
+
+	runner.Env = env
+	runner.VolumeMounts = mounts
+
+	spec.Containers = []corev1.Container{*runner}
+	spec.InitContainers = initContainers
+	spec.Volumes = volumes
+}
+
+// addApplyHooks adds the hooks of the apply Job to a pod copied from the plan Job, the pre-apply hooks run
+// before the Terraform Runner applies the saved plan
+func (t *Terraform) addApplyHooks(spec *corev1.PodSpec) {
+	removePostPlanHooks(spec)
+
+	spec.InitContainers = append(spec.InitContainers, getHookContainers(preApplyHookPrefix, t.getHooks().PreApply, nil, nil)...)
+
+	prefix, postRunHooks := t.getPostRunHooks()
+
+	runHooksAfterRunner(spec, getHookContainers(prefix, postRunHooks, nil, nil))
+}
+
+// getFailedHook returns the container name and the termination message of the latest failed hook
+func getFailedHook(pods []corev1.Pod) (string, string) {
+	var latest *corev1.ContainerStateTerminated
+	name := ""
+
+	for _, pod := range pods {
+		statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+
+		for _, status := range statuses {
+			terminated := status.State.Terminated
+
+			if !isHookContainer(status.Name) || terminated == nil || terminated.ExitCode == 0 {
+				continue
+			}
+
+			if latest == nil || latest.FinishedAt.Before(&terminated.FinishedAt) {
+				latest = terminated
+				name = status.Name
+			}
+		}
+	}
+
+	if latest == nil {
+		return "", ""
+	}
+
+	return name, latest.Message
+}
+
+// GetFailedHook returns the container name and the termination message of the hook that failed the run,
+// an empty name is returned if no hook failed
+func (t *Terraform) GetFailedHook(ctx context.Context) (string, string, error) {
+	pods, err := listPodsForRun(ctx, t.Name, t.Namespace, t.Status.RunID)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	name, message := getFailedHook(pods)
+
+	return name, message, nil
+}
+
+// getPlanCost returns the cost reported by the post-plan hooks of the succeeded plan pods, the result of
+// the last hook that reports a cost is used
+func (t *Terraform) getPlanCost(pods []corev1.Pod) *CostEstimate {
+	var cost *CostEstimate
+
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+
+		for _, hook := range t.getPostPlanHooks() {
+			name := getHookContainerName(postPlanHookPrefix, hook.Name)
+
+			for _, status := range pod.Status.ContainerStatuses {
+				terminated := status.State.Terminated
+
+				if status.Name != name || terminated == nil || terminated.ExitCode != 0 {
+					continue
+				}
+
+				result := struct {
+					Cost *CostEstimate `json:"cost"`
+				}{}
+
+				if err := json.Unmarshal([]byte(strings.TrimSpace(terminated.Message)), &result); err != nil {
+					continue
+				}
+
+				if result.Cost != nil {
+					cost = result.Cost
+				}
+			}
+		}
+	}
+
+	return cost
+}
+
+// setPlanCost records the cost reported by the post-plan hooks in the plan summary, the summary of the apply
+// keeps the cost recorded for the plan of the same run
+func (t *Terraform) setPlanCost(ctx context.Context, summary *PlanSummary) error {
+	if t.Status.Stage != StagePlan || len(t.getPostPlanHooks()) == 0 {
+		if last := t.Status.LastPlan; last != nil && last.RunID == summary.RunID {
+			summary.Cost = last.Cost
+		}
+
+		return nil
+	}
+
+	pods, err := listPodsForRun(ctx, t.Name, t.Namespace, t.Status.RunID)
+
+	if err != nil {
+		return err
+	}
+
+	summary.Cost = t.getPlanCost(pods)
+
+	return nil
+}
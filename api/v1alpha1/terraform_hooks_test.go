@@ -0,0 +1,348 @@
+package v1alpha1
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform Hooks", func() {
+	newRun := func(name string) *Terraform {
+		return &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+				Hooks: &Hooks{
+					PostPlan: []Hook{
+						{Name: "infracost", Image: "infracost/infracost:ci-0.10"},
+					},
+				},
+			},
+			Status: TerraformStatus{
+				RunID: "abc123",
+			},
+		}
+	}
+
+	newPod := func(run *Terraform, phase corev1.PodPhase, exitCode int32, message string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      getPlanJobName(run.Name, run.Status.RunID) + "-" + random(5),
+				Namespace: run.Namespace,
+				Labels:    getCommonLabels(run.Name, run.Status.RunID),
+			},
+			Status: corev1.PodStatus{
+				Phase: phase,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: getHookContainerName(postPlanHookPrefix, "infracost"),
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{
+								ExitCode: exitCode,
+								Message:  message,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	Context("Post-Plan Hooks", func() {
+		It("should run the plan in a separate job when post-plan hooks are set", func() {
+			run := newRun("hooks-separate")
+
+			Expect(run.usesSeparatePlan()).To(BeTrue())
+
+			run.Spec.Hooks = &Hooks{}
+
+			Expect(run.usesSeparatePlan()).To(BeFalse())
+		})
+
+		It("should run the hooks after the runner in the plan job", func() {
+			run := newRun("hooks-plan-job")
+			run.Status.Stage = StagePlan
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			spec := job.Spec.Template.Spec
+
+			Expect(spec.InitContainers).To(HaveLen(2))
+			Expect(spec.InitContainers[1].Name).To(Equal(runnerContainerName))
+			Expect(spec.InitContainers[1].Env).To(ContainElement(getEnvVariable("PLAN_JSON_PATH", "/tmp/plan/plan.json")))
+
+			Expect(spec.Containers).To(HaveLen(1))
+			Expect(spec.Containers[0].Name).To(Equal("post-plan-infracost"))
+			Expect(spec.Containers[0].Image).To(Equal("infracost/infracost:ci-0.10"))
+			Expect(spec.Containers[0].VolumeMounts).To(ContainElement(getVolumeMountSpec(planOutputVolume, planOutputMountPath, true)))
+		})
+
+		It("should not run the hooks outside of the plan job", func() {
+			run := newRun("hooks-single-job")
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(job.Spec.Template.Spec.Containers).To(HaveLen(1))
+			Expect(job.Spec.Template.Spec.Containers[0].Name).To(Equal(runnerContainerName))
+		})
+
+		It("should restore the runner in the apply job", func() {
+			run := newRun("hooks-apply")
+
+			_, err := run.CreateTerraformRun(context.Background(), types.NamespacedName{Name: run.Name, Namespace: run.Namespace})
+			Expect(err).ToNot(HaveOccurred())
+
+			job, err := run.CreateApplyJob(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+
+			spec := job.Spec.Template.Spec
+
+			Expect(spec.InitContainers).To(HaveLen(1))
+			Expect(spec.Containers).To(HaveLen(1))
+			Expect(spec.Containers[0].Name).To(Equal(runnerContainerName))
+			Expect(spec.Containers[0].Env).ToNot(ContainElement(getEnvVariable("PLAN_JSON_PATH", "/tmp/plan/plan.json")))
+
+			for _, volume := range spec.Volumes {
+				Expect(volume.Name).ToNot(Equal(planOutputVolume))
+			}
+		})
+	})
+
+	Context("Run Hooks", func() {
+		newHookedRun := func(name string) *Terraform {
+			run := newRun(name)
+			run.Spec.Hooks = &Hooks{
+				PreInit:     []Hook{{Name: "credentials", Image: "vault:1.11"}},
+				PreApply:    []Hook{{Name: "checkov", Image: "bridgecrew/checkov:2"}},
+				PostApply:   []Hook{{Name: "notify", Image: "curlimages/curl:7.84.0"}},
+				PostDestroy: []Hook{{Name: "cleanup", Image: "busybox:1.35"}},
+			}
+
+			return run
+		}
+
+		getNames := func(containers []corev1.Container) []string {
+			names := []string{}
+
+			for _, c := range containers {
+				names = append(names, c.Name)
+			}
+
+			return names
+		}
+
+		It("should run the pre-init hooks before the runner with the working directory mounted", func() {
+			run := newHookedRun("hooks-pre-init")
+			run.Spec.Hooks.PreApply = nil
+			run.Spec.Hooks.PostApply = nil
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			spec := job.Spec.Template.Spec
+
+			Expect(getNames(spec.InitContainers)).To(Equal([]string{"busybox", "pre-init-credentials"}))
+			Expect(getNames(spec.Containers)).To(Equal([]string{runnerContainerName}))
+			Expect(spec.InitContainers[1].VolumeMounts).To(ContainElement(getVolumeMountSpec(emptyDirVolumeName, moduleWorkingDirMountPath, false)))
+			Expect(spec.InitContainers[1].Env).To(ContainElement(getEnvVariable("TERRAFORM_WORKING_DIR", moduleWorkingDirMountPath)))
+		})
+
+		It("should run the post-apply hooks after the runner", func() {
+			run := newHookedRun("hooks-post-apply")
+			run.Spec.Hooks.PreApply = nil
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(getNames(job.Spec.Template.Spec.InitContainers)).To(Equal([]string{"busybox", "pre-init-credentials", runnerContainerName}))
+			Expect(getNames(job.Spec.Template.Spec.Containers)).To(Equal([]string{"post-apply-notify"}))
+		})
+
+		It("should run the post-destroy hooks after a destroy", func() {
+			run := newHookedRun("hooks-post-destroy")
+			run.Spec.Hooks.PreApply = nil
+			run.Spec.Destroy = true
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(getNames(job.Spec.Template.Spec.Containers)).To(Equal([]string{"post-destroy-cleanup"}))
+		})
+
+		It("should run the pre-apply hooks before the runner applies the saved plan", func() {
+			run := newHookedRun("hooks-pre-apply")
+
+			Expect(run.usesSeparatePlan()).To(BeTrue())
+
+			planJob, err := run.CreateTerraformRun(context.Background(), types.NamespacedName{Name: run.Name, Namespace: run.Namespace})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(getNames(planJob.Spec.Template.Spec.InitContainers)).To(Equal([]string{"busybox", "pre-init-credentials"}))
+			Expect(getNames(planJob.Spec.Template.Spec.Containers)).To(Equal([]string{runnerContainerName}))
+
+			job, err := run.CreateApplyJob(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(getNames(job.Spec.Template.Spec.InitContainers)).To(Equal([]string{"busybox", "pre-init-credentials", "pre-apply-checkov", runnerContainerName}))
+			Expect(getNames(job.Spec.Template.Spec.Containers)).To(Equal([]string{"post-apply-notify"}))
+		})
+
+		It("should not retry the jobs that run the post-run hooks", func() {
+			run := newHookedRun("hooks-backoff")
+			run.Spec.RetryLimit = 3
+
+			planJob, err := run.CreateTerraformRun(context.Background(), types.NamespacedName{Name: run.Name, Namespace: run.Namespace})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*planJob.Spec.BackoffLimit).To(Equal(int32(3)))
+
+			job, err := run.CreateApplyJob(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*job.Spec.BackoffLimit).To(Equal(int32(0)))
+
+			run = newHookedRun("hooks-backoff-single")
+			run.Spec.RetryLimit = 3
+			run.Spec.Hooks.PreApply = nil
+			run.Spec.Hooks.PostPlan = nil
+
+			job, err = getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*job.Spec.BackoffLimit).To(Equal(int32(0)))
+
+			run.Spec.Hooks.PostApply = nil
+
+			job, err = getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*job.Spec.BackoffLimit).To(Equal(int32(3)))
+		})
+
+		It("should return the latest failed hook", func() {
+			pods := []corev1.Pod{
+				{
+					Status: corev1.PodStatus{
+						InitContainerStatuses: []corev1.ContainerStatus{
+							{
+								Name: runnerContainerName,
+								State: corev1.ContainerState{
+									Terminated: &corev1.ContainerStateTerminated{ExitCode: 0},
+								},
+							},
+						},
+						ContainerStatuses: []corev1.ContainerStatus{
+							{
+								Name: "post-apply-notify",
+								State: corev1.ContainerState{
+									Terminated: &corev1.ContainerStateTerminated{ExitCode: 7, Message: "could not resolve host"},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			name, message := getFailedHook(pods)
+
+			Expect(name).To(Equal("post-apply-notify"))
+			Expect(message).To(Equal("could not resolve host"))
+		})
+
+		It("should not report the runner as a failed hook", func() {
+			pods := []corev1.Pod{
+				{
+					Status: corev1.PodStatus{
+						ContainerStatuses: []corev1.ContainerStatus{
+							{
+								Name: runnerContainerName,
+								State: corev1.ContainerState{
+									Terminated: &corev1.ContainerStateTerminated{ExitCode: 1},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			name, _ := getFailedHook(pods)
+
+			Expect(name).To(BeEmpty())
+		})
+	})
+
+	Context("Plan Cost", func() {
+		It("should return the cost reported by a succeeded hook", func() {
+			run := newRun("hooks-cost")
+
+			pods := []corev1.Pod{
+				*newPod(run, corev1.PodFailed, 1, `{"cost": {"monthlyCostDelta": "999"}}`),
+				*newPod(run, corev1.PodSucceeded, 0, `{"cost": {"currency": "USD", "monthlyCost": "120.5", "pastMonthlyCost": "100", "monthlyCostDelta": "20.5"}}`),
+			}
+
+			Expect(run.getPlanCost(pods)).To(Equal(&CostEstimate{
+				Currency:         "USD",
+				MonthlyCost:      "120.5",
+				PastMonthlyCost:  "100",
+				MonthlyCostDelta: "20.5",
+			}))
+		})
+
+		It("should ignore a result that is not JSON", func() {
+			run := newRun("hooks-no-cost")
+
+			Expect(run.getPlanCost([]corev1.Pod{*newPod(run, corev1.PodSucceeded, 0, "done")})).To(BeNil())
+		})
+
+		It("should record the cost in the plan summary", func() {
+			run := newRun("hooks-summary")
+			run.Status.Stage = StagePlan
+
+			pod := newPod(run, corev1.PodSucceeded, 0, `{"cost": {"currency": "USD", "monthlyCostDelta": "12"}}`)
+
+			_, err := kube.ClientSet.CoreV1().Pods(run.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      getPlanChunkSecretName(run.Name, run.Status.RunID, 0),
+					Namespace: run.Namespace,
+				},
+				Data: map[string][]byte{planJSONKey: []byte(`{"resource_changes": []}`)},
+			}
+
+			_, err = kube.ClientSet.CoreV1().Secrets(run.Namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(run.SetPlanSummary(context.Background())).To(Succeed())
+			Expect(run.Status.LastPlan.Cost).ToNot(BeNil())
+			Expect(run.Status.LastPlan.Cost.MonthlyCostDelta).To(Equal("12"))
+
+			run.Status.Stage = StageApply
+
+			Expect(run.SetPlanSummary(context.Background())).To(Succeed())
+			Expect(run.Status.LastPlan.Cost).ToNot(BeNil())
+			Expect(run.Status.LastPlan.Cost.MonthlyCostDelta).To(Equal("12"))
+
+			run.Status.RunID = "next"
+			secret.Name = getPlanChunkSecretName(run.Name, run.Status.RunID, 0)
+
+			_, err = kube.ClientSet.CoreV1().Secrets(run.Namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(run.SetPlanSummary(context.Background())).To(Succeed())
+			Expect(run.Status.LastPlan).ToNot(BeNil())
+			Expect(run.Status.LastPlan.Cost).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,365 @@
+package v1alpha1
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrPlanOutdated is returned when the module or the inputs of a run changed since its plan was made
+var ErrPlanOutdated = errors.New("the plan is outdated")
+
+// jobControllerLabels are set on the pod template by the Job controller, they are not copied to the apply Job
+var jobControllerLabels = []string{"controller-uid", "job-name", "batch.kubernetes.io/controller-uid", "batch.kubernetes.io/job-name"}
+
+// keys of the plan Secrets written by the Terraform Runner
+const (
+	// planBinaryKey holds the binary plan saved with `terraform plan -out`
+	planBinaryKey = "tfplan"
+	// planJSONKey holds the `terraform show -json` rendering of the plan
+	planJSONKey = "plan.json"
+	// planTextKey holds the `terraform show` rendering of the plan
+	planTextKey = "plan.txt"
+)
+
+// planResourceChanges is the subset of the `terraform show -json` rendering the plan summary is computed from
+type planResourceChanges struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Change  struct {
+			Actions []string `json:"actions"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// getPlanSummary counts the resources to add, change and destroy in the JSON rendering of a plan,
+// a replaced resource is counted as added and destroyed
+func getPlanSummary(data []byte) (*PlanSummary, error) {
+	plan := planResourceChanges{}
+
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse the plan: %w", err)
+	}
+
+	summary := &PlanSummary{}
+
+	for _, rc := range plan.ResourceChanges {
+		for _, action := range rc.Change.Actions {
+			switch action {
+			case "create":
+				summary.Add++
+			case "update":
+				summary.Change++
+			case "delete":
+				summary.Destroy++
+			}
+		}
+	}
+
+	return summary, nil
+}
+
+// GetPlan returns the plan of the run reassembled from its chunk Secrets, keyed by `tfplan`, `plan.json`
+// and `plan.txt`. Nil is returned if the plan was not saved
+func (t *Terraform) GetPlan(ctx context.Context) (map[string][]byte, error) {
+	chunks, err := getPlanChunks(ctx, t.Name, t.Namespace, t.Status.RunID)
+
+	if err != nil || chunks == nil {
+		return nil, err
+	}
+
+	if err := adoptPlanChunks(ctx, chunks, t); err != nil {
+		return nil, err
+	}
+
+	plan := map[string][]byte{}
+
+	for _, chunk := range chunks {
+		for _, key := range []string{planBinaryKey, planJSONKey, planTextKey} {
+			plan[key] = append(plan[key], chunk.Data[key]...)
+		}
+	}
+
+	return plan, nil
+}
+
+// SetPlanSummary summarises the plan of the run into the status with the cost reported by the post-plan
+// hooks, the summary is left as is if the plan was not saved
+func (t *Terraform) SetPlanSummary(ctx context.Context) error {
+	plan, err := t.GetPlan(ctx)
+
+	if err != nil || plan == nil {
+		return err
+	}
+
+	summary, err := getPlanSummary(plan[planJSONKey])
+
+	if err != nil {
+		return err
+	}
+
+	summary.RunID = t.Status.RunID
+	summary.SecretName = getPlanSecretName(t.Name, t.Status.RunID)
+
+	if err := t.setPlanCost(ctx, summary); err != nil {
+		return err
+	}
+
+	t.Status.LastPlan = summary
+
+	return nil
+}
+
+// usesSeparatePlan evaluates if the plan runs in a separate Job, the plan is checked against the protection
+// and the policies, and the post-plan and the pre-apply hooks run between the plan and the apply Jobs
+func (t *Terraform) usesSeparatePlan() bool {
+	return t.Spec.SeparatePlan || t.Spec.Protection != nil || t.hasPolicies() || len(t.getPostPlanHooks()) > 0 || len(t.getHooks().PreApply) > 0
+}
+
+// getJobName returns the name of the Job of the current stage of the run
+func (t *Terraform) getJobName() string {
+	if t.Status.Stage == StagePlan {
+		return getPlanJobName(t.Name, t.Status.RunID)
+	}
+
+	return getUniqueResourceName(t.Name, t.Status.RunID)
+}
+
+// getInputHash returns a hash of the rendered module and the inputs of the Terraform Runner of a Job, including
+// the values the variables and the variable files read from ConfigMaps and Secrets. The stage of the Job is
+// left out so that the plan and the apply Jobs of a run have the same hash
+func (t *Terraform) getInputHash(ctx context.Context, job *batchv1.Job) (string, error) {
+	cm, err := kube.ClientSet.CoreV1().ConfigMaps(t.Namespace).Get(ctx, getUniqueResourceName(t.Name, t.Status.RunID), metav1.GetOptions{})
+
+	if err != nil {
+		return "", err
+	}
+
+	inputs := struct {
+		Module     map[string]string `json:"module"`
+		Env        []corev1.EnvVar   `json:"env"`
+		Volumes    []corev1.Volume   `json:"volumes"`
+		References map[string][]byte `json:"references"`
+	}{
+		Module:     cm.Data,
+		Env:        []corev1.EnvVar{},
+		Volumes:    job.Spec.Template.Spec.Volumes,
+		References: map[string][]byte{},
+	}
+
+	if runner := getRunnerContainer(&job.Spec.Template.Spec); runner != nil {
+		for _, env := range runner.Env {
+			if env.Name == "TERRAFORM_STAGE" || env.Name == "PLAN_HASH" {
+				continue
+			}
+
+			inputs.Env = append(inputs.Env, env)
+
+			if err := addEnvReference(ctx, inputs.References, t.Namespace, env); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	for _, file := range t.Spec.VariableFiles {
+		if err := addVolumeReference(ctx, inputs.References, t.Namespace, file.ValueFrom); err != nil {
+			return "", err
+		}
+	}
+
+	data, err := json.Marshal(inputs)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// getReferencedData returns the data of a ConfigMap or a Secret an input of the Terraform Runner refers to,
+// nil is returned if it does not exist
+func getReferencedData(ctx context.Context, kind string, name string, namespace string) (map[string][]byte, error) {
+	data := map[string][]byte{}
+
+	if kind == "secret" {
+		secret, err := isSecretExist(ctx, name, namespace)
+
+		if err != nil || secret == nil {
+			return nil, err
+		}
+
+		for key, value := range secret.Data {
+			data[key] = value
+		}
+
+		return data, nil
+	}
+
+	cm, err := kube.ClientSet.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	for key, value := range cm.Data {
+		data[key] = []byte(value)
+	}
+
+	for key, value := range cm.BinaryData {
+		data[key] = value
+	}
+
+	return data, nil
+}
+
+// addReference adds the values of the keys of a referenced ConfigMap or Secret to the references of the
+// inputs, all the keys are added if none are given
+func addReference(ctx context.Context, references map[string][]byte, kind string, name string, namespace string, keys ...string) error {
+	data, err := getReferencedData(ctx, kind, name, namespace)
+
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		for key := range data {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		if value, found := data[key]; found {
+			references[fmt.Sprintf("%s/%s/%s", kind, name, key)] = value
+		}
+	}
+
+	return nil
+}
+
+// addEnvReference adds the value an environment variable reads from a ConfigMap or a Secret to the references
+// of the inputs, so that a change of the value is detected and not only a change of the reference
+func addEnvReference(ctx context.Context, references map[string][]byte, namespace string, env corev1.EnvVar) error {
+	if env.ValueFrom == nil {
+		return nil
+	}
+
+	if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+		return addReference(ctx, references, "configmap", ref.Name, namespace, ref.Key)
+	}
+
+	if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+		return addReference(ctx, references, "secret", ref.Name, namespace, ref.Key)
+	}
+
+	return nil
+}
+
+// addVolumeReference adds the contents of the ConfigMap or the Secret a variable file is mounted from to the
+// references of the inputs, other volume sources are only hashed by reference
+func addVolumeReference(ctx context.Context, references map[string][]byte, namespace string, source *corev1.VolumeSource) error {
+	if source == nil {
+		return nil
+	}
+
+	if source.ConfigMap != nil {
+		return addReference(ctx, references, "configmap", source.ConfigMap.Name, namespace, getKeysOfItems(source.ConfigMap.Items)...)
+	}
+
+	if source.Secret != nil {
+		return addReference(ctx, references, "secret", source.Secret.SecretName, namespace, getKeysOfItems(source.Secret.Items)...)
+	}
+
+	return nil
+}
+
+// getKeysOfItems returns the keys of the projected items of a volume
+func getKeysOfItems(items []corev1.KeyToPath) []string {
+	keys := []string{}
+
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+
+	return keys
+}
+
+// CreateApplyJob creates the apply Job of a run with a separate plan. The apply Job is a copy of the plan Job
+// that applies the saved plan, it is refused with ErrPlanOutdated if the spec, the module or the inputs
+// changed since planning
+func (t *Terraform) CreateApplyJob(ctx context.Context) (*batchv1.Job, error) {
+	if t.Status.SpecHash != "" && t.Status.SpecHash != t.GetSpecHash() {
+		return nil, fmt.Errorf("%w, the spec changed since planning", ErrPlanOutdated)
+	}
+
+	planJob, err := getJobForRun(ctx, getPlanJobName(t.Name, t.Status.RunID), t.Namespace)
+
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := t.getInputHash(ctx, planJob)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if hash != t.Status.PlanHash {
+		return nil, fmt.Errorf("%w, the module or the inputs changed since planning", ErrPlanOutdated)
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            getUniqueResourceName(t.Name, t.Status.RunID),
+			Namespace:       t.Namespace,
+			Labels:          getCommonLabels(t.Name, t.Status.RunID),
+			Annotations:     planJob.Annotations,
+			OwnerReferences: planJob.OwnerReferences,
+		},
+		Spec: *planJob.Spec.DeepCopy(),
+	}
+
+	job.Spec.Selector = nil
+	job.Spec.ManualSelector = nil
+	job.Spec.Suspend = nil
+	job.Spec.TTLSecondsAfterFinished = t.Spec.JobTTLSecondsAfterFinished
+	job.Spec.BackoffLimit = t.getApplyBackoffLimit()
+
+	for _, label := range jobControllerLabels {
+		delete(job.Spec.Template.Labels, label)
+	}
+
+	// the post-plan hooks only run in the plan job
+	t.addApplyHooks(&job.Spec.Template.Spec)
+
+	if runner := getRunnerContainer(&job.Spec.Template.Spec); runner != nil {
+		for i, env := range runner.Env {
+			if env.Name == "TERRAFORM_STAGE" {
+				runner.Env[i].Value = "apply"
+			}
+		}
+
+		runner.Env = append(runner.Env, getEnvVariable("PLAN_HASH", hash))
+	}
+
+	created, err := kube.ClientSet.BatchV1().Jobs(t.Namespace).Create(ctx, job, metav1.CreateOptions{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.Status.Stage = StageApply
+
+	return created, nil
+}
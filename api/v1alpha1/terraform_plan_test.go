@@ -0,0 +1,317 @@
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform Plan", func() {
+	planJSON := `{
+  "format_version": "1.0",
+  "resource_changes": [
+    {"address": "null_resource.a", "change": {"actions": ["create"]}},
+    {"address": "null_resource.b", "change": {"actions": ["update"]}},
+    {"address": "null_resource.c", "change": {"actions": ["delete"]}},
+    {"address": "null_resource.d", "change": {"actions": ["delete", "create"]}},
+    {"address": "null_resource.e", "change": {"actions": ["no-op"]}},
+    {"address": "data.null_data_source.f", "change": {"actions": ["read"]}}
+  ]
+}`
+
+	newRun := func(name string) *Terraform {
+		return &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				UID:       "plan-uid",
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+			},
+			Status: TerraformStatus{
+				RunID: "abc123",
+			},
+		}
+	}
+
+	createChunk := func(run *Terraform, index int, count string, data map[string][]byte) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        getPlanChunkSecretName(run.Name, run.Status.RunID, index),
+				Namespace:   run.Namespace,
+				Annotations: map[string]string{PlanChunksAnnotation: count},
+			},
+			Data: data,
+		}
+
+		_, err := kube.ClientSet.CoreV1().Secrets(run.Namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	Context("Plan Summary", func() {
+		It("should count the resources to add, change and destroy", func() {
+			summary, err := getPlanSummary([]byte(planJSON))
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(summary.Add).To(Equal(int32(2)))
+			Expect(summary.Change).To(Equal(int32(1)))
+			Expect(summary.Destroy).To(Equal(int32(2)))
+		})
+
+		It("should fail on an invalid plan", func() {
+			_, err := getPlanSummary([]byte("not json"))
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should pass the plan secret name to the terraform runner", func() {
+			run := newRun("plan-env")
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(getEnvVariable("PLAN_SECRET_NAME", "plan-env-abc123-plan")))
+		})
+	})
+
+	Context("Plan Artifact", func() {
+		It("should reassemble the plan from its chunks and adopt them", func() {
+			run := newRun("plan-chunked")
+
+			createChunk(run, 0, "2", map[string][]byte{
+				planBinaryKey: []byte("binary-"),
+				planJSONKey:   []byte(planJSON[:40]),
+				planTextKey:   []byte("Plan: 2 to add, "),
+			})
+			createChunk(run, 1, "2", map[string][]byte{
+				planBinaryKey: []byte("plan"),
+				planJSONKey:   []byte(planJSON[40:]),
+				planTextKey:   []byte("1 to change, 2 to destroy."),
+			})
+
+			plan, err := run.GetPlan(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(plan[planBinaryKey])).To(Equal("binary-plan"))
+			Expect(string(plan[planJSONKey])).To(Equal(planJSON))
+			Expect(string(plan[planTextKey])).To(Equal("Plan: 2 to add, 1 to change, 2 to destroy."))
+
+			chunk, err := kube.ClientSet.CoreV1().Secrets(run.Namespace).Get(context.Background(), getPlanChunkSecretName(run.Name, run.Status.RunID, 1), metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(chunk.OwnerReferences).To(HaveLen(1))
+			Expect(chunk.Labels["terraformRunId"]).To(Equal(run.Status.RunID))
+
+			Expect(run.SetPlanSummary(context.Background())).ToNot(HaveOccurred())
+			Expect(run.Status.LastPlan).ToNot(BeNil())
+			Expect(run.Status.LastPlan.RunID).To(Equal("abc123"))
+			Expect(run.Status.LastPlan.SecretName).To(Equal("plan-chunked-abc123-plan"))
+			Expect(run.Status.LastPlan.Add).To(Equal(int32(2)))
+
+			Expect(deletePlanByRun(context.Background(), run.Name, run.Namespace, run.Status.RunID)).ToNot(HaveOccurred())
+
+			plan, err = run.GetPlan(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(plan).To(BeNil())
+		})
+
+		It("should keep the previous summary if the plan was not saved", func() {
+			run := newRun("plan-missing")
+			run.Status.LastPlan = &PlanSummary{RunID: "previous", Add: 1}
+
+			Expect(run.SetPlanSummary(context.Background())).ToNot(HaveOccurred())
+			Expect(run.Status.LastPlan.RunID).To(Equal("previous"))
+		})
+
+		It("should fail if a chunk is missing", func() {
+			run := newRun("plan-incomplete")
+
+			createChunk(run, 0, "3", map[string][]byte{planJSONKey: []byte("{")})
+
+			_, err := run.GetPlan(context.Background())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Separate Plan", func() {
+		newSeparateRun := func(name string) (*Terraform, types.NamespacedName) {
+			run := newRun(name)
+			run.Spec.SeparatePlan = true
+
+			return run, types.NamespacedName{Name: run.Name, Namespace: run.Namespace}
+		}
+
+		getEnv := func(job *batchv1.Job, name string) string {
+			for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == name {
+					return env.Value
+				}
+			}
+
+			return ""
+		}
+
+		getPlanJobOrFail := func(run *Terraform) *batchv1.Job {
+			job, err := getJobForRun(context.Background(), getPlanJobName(run.Name, run.Status.RunID), run.Namespace)
+			Expect(err).ToNot(HaveOccurred())
+
+			return job
+		}
+
+		It("should start the run with a plan job", func() {
+			run, key := newSeparateRun("separate-plan")
+
+			job, err := run.CreateTerraformRun(context.Background(), key)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(job.Name).To(Equal("separate-plan-abc123-plan"))
+			Expect(getEnv(job, "TERRAFORM_STAGE")).To(Equal("plan"))
+			Expect(run.Status.Stage).To(Equal(StagePlan))
+			Expect(run.Status.PlanHash).ToNot(BeEmpty())
+
+			current, err := run.GetJobByRun(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(current.Name).To(Equal(job.Name))
+		})
+
+		It("should apply the saved plan with the inputs of the plan job", func() {
+			run, key := newSeparateRun("separate-apply")
+			ttl := int32(60)
+			run.Spec.JobTTLSecondsAfterFinished = &ttl
+
+			planJob, err := run.CreateTerraformRun(context.Background(), key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(planJob.Spec.TTLSecondsAfterFinished).To(BeNil())
+
+			job, err := run.CreateApplyJob(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(job.Name).To(Equal("separate-apply-abc123"))
+			Expect(getEnv(job, "TERRAFORM_STAGE")).To(Equal("apply"))
+			Expect(getEnv(job, "PLAN_HASH")).To(Equal(run.Status.PlanHash))
+			Expect(*job.Spec.TTLSecondsAfterFinished).To(Equal(int32(60)))
+			Expect(run.Status.Stage).To(Equal(StageApply))
+
+			Expect(deleteJobByRun(context.Background(), run.Name, run.Namespace, run.Status.RunID)).ToNot(HaveOccurred())
+
+			_, err = getJobForRun(context.Background(), getPlanJobName(run.Name, run.Status.RunID), run.Namespace)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should refuse to apply if the module changed since planning", func() {
+			run, key := newSeparateRun("separate-module")
+
+			_, err := run.CreateTerraformRun(context.Background(), key)
+			Expect(err).ToNot(HaveOccurred())
+
+			configMaps := kube.ClientSet.CoreV1().ConfigMaps(run.Namespace)
+
+			cm, err := configMaps.Get(context.Background(), getUniqueResourceName(run.Name, run.Status.RunID), metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			cm.Data["main.tf"] = "# changed"
+
+			_, err = configMaps.Update(context.Background(), cm, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = run.CreateApplyJob(context.Background())
+			Expect(errors.Is(err, ErrPlanOutdated)).To(BeTrue())
+		})
+
+		It("should refuse to apply if a referenced value changed since planning", func() {
+			run, key := newSeparateRun("separate-refs")
+			run.Spec.Variables = []Variable{{
+				Key: "password",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "separate-refs-secret"},
+						Key:                  "password",
+					},
+				},
+			}}
+			run.Spec.VariableFiles = []VariableFile{{
+				Key: "common",
+				ValueFrom: &corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "separate-refs-vars"},
+					},
+				},
+			}}
+
+			secrets := kube.ClientSet.CoreV1().Secrets(run.Namespace)
+			configMaps := kube.ClientSet.CoreV1().ConfigMaps(run.Namespace)
+
+			secret, err := secrets.Create(context.Background(), &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "separate-refs-secret", Namespace: run.Namespace},
+				Data:       map[string][]byte{"password": []byte("one"), "unused": []byte("one")},
+			}, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			vars, err := configMaps.Create(context.Background(), &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "separate-refs-vars", Namespace: run.Namespace},
+				Data:       map[string]string{"common.tfvars": `length = 16`},
+			}, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = run.CreateTerraformRun(context.Background(), key)
+			Expect(err).ToNot(HaveOccurred())
+
+			secret.Data["unused"] = []byte("two")
+			secret, err = secrets.Update(context.Background(), secret, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			hash, err := run.getInputHash(context.Background(), getPlanJobOrFail(run))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hash).To(Equal(run.Status.PlanHash))
+
+			vars.Data["common.tfvars"] = `length = 32`
+			_, err = configMaps.Update(context.Background(), vars, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = run.CreateApplyJob(context.Background())
+			Expect(errors.Is(err, ErrPlanOutdated)).To(BeTrue())
+
+			vars.Data["common.tfvars"] = `length = 16`
+			_, err = configMaps.Update(context.Background(), vars, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			secret.Data["password"] = []byte("two")
+			_, err = secrets.Update(context.Background(), secret, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = run.CreateApplyJob(context.Background())
+			Expect(errors.Is(err, ErrPlanOutdated)).To(BeTrue())
+		})
+
+		It("should refuse to apply if the spec changed since planning", func() {
+			run, key := newSeparateRun("separate-spec")
+
+			_, err := run.CreateTerraformRun(context.Background(), key)
+			Expect(err).ToNot(HaveOccurred())
+
+			run.Status.SpecHash = run.GetSpecHash()
+			run.Spec.Variables = []Variable{{Key: "length", Value: "32"}}
+
+			_, err = run.CreateApplyJob(context.Background())
+			Expect(errors.Is(err, ErrPlanOutdated)).To(BeTrue())
+		})
+
+		It("should restart a run in the plan stage", func() {
+			run, _ := newSeparateRun("separate-restart")
+			run.Spec.UpdatePolicy = UpdatePolicyCancelAndRestart
+			run.Status.Stage = StagePlan
+
+			Expect(run.CanRestart()).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,130 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	"github.com/kuptan/terraform-operator/internal/opa"
+	"github.com/kuptan/terraform-operator/internal/utils"
+)
+
+// policyFileSuffix is the suffix of the ConfigMap keys that hold Rego policies
+const policyFileSuffix string = ".rego"
+
+// getMandatoryPolicies returns the ConfigMaps of the operator-wide policies, the operator namespace is used
+// for the entries that don't specify one
+func getMandatoryPolicies() []types.NamespacedName {
+	refs := []types.NamespacedName{}
+
+	for _, entry := range utils.Env.MandatoryPolicies {
+		ref := types.NamespacedName{
+			Namespace: utils.Env.OperatorNamespace,
+			Name:      entry,
+		}
+
+		if parts := strings.SplitN(entry, "/", 2); len(parts) == 2 {
+			ref.Namespace = parts[0]
+			ref.Name = parts[1]
+		}
+
+		if ref.Namespace == "" {
+			ref.Namespace = "default"
+		}
+
+		refs = append(refs, ref)
+	}
+
+	return refs
+}
+
+// getPolicies returns the ConfigMaps of the policies the plan of the run is evaluated against, the
+// operator-wide policies first
+func (t *Terraform) getPolicies() []types.NamespacedName {
+	refs := getMandatoryPolicies()
+
+	for _, policy := range t.Spec.Policies {
+		refs = append(refs, types.NamespacedName{
+			Namespace: t.Namespace,
+			Name:      policy.Name,
+		})
+	}
+
+	return refs
+}
+
+// hasPolicies evaluates if the plan of the run is checked against policies
+func (t *Terraform) hasPolicies() bool {
+	return len(t.Spec.Policies) > 0 || len(utils.Env.MandatoryPolicies) > 0
+}
+
+// getPolicyModules returns the Rego modules of the policies keyed by '<namespace>/<configmap>/<key>', and the
+// policies that have no Rego modules
+func (t *Terraform) getPolicyModules(ctx context.Context) (map[string]string, []types.NamespacedName, error) {
+	modules := map[string]string{}
+	empty := []types.NamespacedName{}
+
+	for _, ref := range t.getPolicies() {
+		cm, err := kube.ClientSet.CoreV1().ConfigMaps(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get the policy configmap %s: %w", ref.String(), err)
+		}
+
+		found := false
+
+		for key, module := range cm.Data {
+			if strings.HasSuffix(key, policyFileSuffix) {
+				modules[ref.String()+"/"+key] = module
+				found = true
+			}
+		}
+
+		if !found {
+			empty = append(empty, ref)
+		}
+	}
+
+	return modules, empty, nil
+}
+
+// CheckPolicies evaluates the saved plan of the run against its policies and the operator-wide policies
+// with the OPA server of the operator. The deny results are returned as violations, a plan that was not
+// saved or a policy without Rego modules is a violation as well
+func (t *Terraform) CheckPolicies(ctx context.Context) ([]string, error) {
+	if !t.hasPolicies() {
+		return nil, nil
+	}
+
+	modules, empty, err := t.getPolicyModules(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(empty) > 0 {
+		violations := []string{}
+
+		for _, ref := range empty {
+			violations = append(violations, fmt.Sprintf("the policy configmap %s has no %s policies", ref.String(), policyFileSuffix))
+		}
+
+		return violations, nil
+	}
+
+	plan, err := t.GetPlan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if plan == nil || len(plan[planJSONKey]) == 0 {
+		return []string{"the plan was not saved, the policies cannot be evaluated"}, nil
+	}
+
+	return opa.NewClient(utils.Env.OPAURL).Evaluate(ctx, modules, plan[planJSONKey])
+}
@@ -0,0 +1,181 @@
+package v1alpha1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	"github.com/kuptan/terraform-operator/internal/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform Policy", func() {
+	var server *httptest.Server
+	var loaded []string
+
+	newRun := func(name string, policies ...string) *Terraform {
+		run := &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+			},
+			Status: TerraformStatus{
+				RunID: "abc123",
+			},
+		}
+
+		for _, policy := range policies {
+			run.Spec.Policies = append(run.Spec.Policies, PolicyRef{Name: policy})
+		}
+
+		return run
+	}
+
+	createPolicy := func(namespace string, name string, data map[string]string) {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Data: data,
+		}
+
+		_, err := kube.ClientSet.CoreV1().ConfigMaps(namespace).Create(context.Background(), cm, metav1.CreateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	savePlan := func(run *Terraform) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      getPlanChunkSecretName(run.Name, run.Status.RunID, 0),
+				Namespace: run.Namespace,
+			},
+			Data: map[string][]byte{planJSONKey: []byte(`{"resource_changes": []}`)},
+		}
+
+		_, err := kube.ClientSet.CoreV1().Secrets(run.Namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		loaded = []string{}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPut {
+				loaded = append(loaded, strings.TrimPrefix(r.URL.Path, "/v1/policies/terraform-operator/"))
+			}
+
+			if r.URL.Path == "/v1/data/terraform/deny" {
+				_, _ = w.Write([]byte(`{"result": ["public buckets are not allowed"]}`))
+				return
+			}
+
+			_, _ = w.Write([]byte(`{}`))
+		}))
+
+		utils.Env.OPAURL = server.URL
+	})
+
+	AfterEach(func() {
+		server.Close()
+
+		utils.Env.OPAURL = ""
+		utils.Env.MandatoryPolicies = nil
+	})
+
+	Context("Policies", func() {
+		It("should run the plan in a separate job when policies are set", func() {
+			Expect(newRun("policy-none").usesSeparatePlan()).To(BeFalse())
+			Expect(newRun("policy-set", "policies").usesSeparatePlan()).To(BeTrue())
+
+			utils.Env.MandatoryPolicies = []string{"mandatory"}
+
+			Expect(newRun("policy-mandatory").usesSeparatePlan()).To(BeTrue())
+		})
+
+		It("should resolve the mandatory policies in the operator namespace", func() {
+			utils.Env.MandatoryPolicies = []string{"baseline", "security/encryption"}
+
+			refs := getMandatoryPolicies()
+
+			Expect(refs).To(HaveLen(2))
+			Expect(refs[0].String()).To(Equal("default/baseline"))
+			Expect(refs[1].String()).To(Equal("security/encryption"))
+		})
+
+		It("should return the violations of the mandatory and the object policies", func() {
+			createPolicy("default", "policy-mandatory-set", map[string]string{
+				"buckets.rego": "package terraform\n\ndeny[msg] { msg := \"public buckets are not allowed\" }",
+			})
+			createPolicy("default", "policy-object-set", map[string]string{
+				"tags.rego": "package terraform.tags\n\ndeny[msg] { false; msg := \"\" }",
+				"README.md": "not a policy",
+			})
+
+			utils.Env.MandatoryPolicies = []string{"policy-mandatory-set"}
+
+			run := newRun("policy-violations", "policy-object-set")
+			savePlan(run)
+
+			violations, err := run.CheckPolicies(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(violations).To(Equal([]string{"terraform: public buckets are not allowed"}))
+			Expect(loaded).To(ConsistOf("default/policy-mandatory-set/buckets.rego", "default/policy-object-set/tags.rego"))
+		})
+
+		It("should deny a plan that was not saved", func() {
+			createPolicy("default", "policy-unsaved", map[string]string{
+				"tags.rego": "package terraform.tags",
+			})
+
+			violations, err := newRun("policy-unsaved", "policy-unsaved").CheckPolicies(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(violations).To(ConsistOf(ContainSubstring("the plan was not saved")))
+		})
+
+		It("should deny a plan if a policy has no rego modules", func() {
+			createPolicy("default", "policy-no-rego", map[string]string{
+				"README.md": "not a policy",
+			})
+			createPolicy("default", "policy-with-rego", map[string]string{
+				"tags.rego": "package terraform.tags",
+			})
+
+			run := newRun("policy-no-rego", "policy-no-rego", "policy-with-rego")
+			savePlan(run)
+
+			violations, err := run.CheckPolicies(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(violations).To(Equal([]string{"the policy configmap default/policy-no-rego has no .rego policies"}))
+			Expect(loaded).To(BeEmpty())
+		})
+
+		It("should fail if a policy configmap does not exist", func() {
+			_, err := newRun("policy-missing", "does-not-exist").CheckPolicies(context.Background())
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should not evaluate without policies", func() {
+			violations, err := newRun("policy-empty").CheckPolicies(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(violations).To(BeNil())
+		})
+	})
+})
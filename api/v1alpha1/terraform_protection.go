@@ -0,0 +1,129 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// getDestroyedResources returns the addresses of the resources a plan destroys, replaced resources included
+func getDestroyedResources(data []byte) ([]string, error) {
+	plan := planResourceChanges{}
+
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse the plan: %w", err)
+	}
+
+	destroyed := []string{}
+
+	for _, rc := range plan.ResourceChanges {
+		for _, action := range rc.Change.Actions {
+			if action == "delete" {
+				destroyed = append(destroyed, rc.Address)
+				break
+			}
+		}
+	}
+
+	return destroyed, nil
+}
+
+// getCostDelta returns the monthly cost delta of the plan summary, false is returned if the cost was not
+// estimated
+func getCostDelta(summary *PlanSummary) (float64, bool) {
+	if summary == nil || summary.Cost == nil {
+		return 0, false
+	}
+
+	delta, err := strconv.ParseFloat(summary.Cost.MonthlyCostDelta, 64)
+
+	if err != nil {
+		return 0, false
+	}
+
+	return delta, true
+}
+
+// checkCost checks the cost of the plan reported by the post-plan hooks against the maximum monthly cost delta
+func (t *Terraform) checkCost() string {
+	max, err := strconv.ParseFloat(t.Spec.Protection.MaxMonthlyCostDelta, 64)
+
+	if err != nil {
+		return fmt.Sprintf("the maximum monthly cost delta is invalid: %s", err.Error())
+	}
+
+	var summary *PlanSummary
+
+	if t.Status.LastPlan != nil && t.Status.LastPlan.RunID == t.Status.RunID {
+		summary = t.Status.LastPlan
+	}
+
+	delta, ok := getCostDelta(summary)
+
+	if !ok {
+		return "the cost of the plan was not estimated, the cost limit cannot be enforced"
+	}
+
+	if delta > max {
+		return fmt.Sprintf("the plan increases the monthly cost by %s, more than the maximum of %s", summary.Cost.MonthlyCostDelta, t.Spec.Protection.MaxMonthlyCostDelta)
+	}
+
+	return ""
+}
+
+// CheckProtection checks the saved plan of the run against its protection. A message is returned with the
+// addresses of the resources the plan destroys if the plan is blocked, a plan that was not saved or cannot
+// be parsed is blocked as well. The cost of the plan is checked once the plan summary is set
+func (t *Terraform) CheckProtection(ctx context.Context) (string, []string, error) {
+	protection := t.Spec.Protection
+
+	if protection == nil {
+		return "", nil, nil
+	}
+
+	if protection.PreventDestroy || protection.MaxDestroyCount != nil {
+		message, destroyed, err := t.checkDestroy(ctx)
+
+		if err != nil || message != "" {
+			return message, destroyed, err
+		}
+	}
+
+	if protection.MaxMonthlyCostDelta != "" {
+		return t.checkCost(), nil, nil
+	}
+
+	return "", nil, nil
+}
+
+// checkDestroy checks the resources the saved plan destroys against the protection
+func (t *Terraform) checkDestroy(ctx context.Context) (string, []string, error) {
+	protection := t.Spec.Protection
+
+	plan, err := t.GetPlan(ctx)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	if plan == nil {
+		return "the plan was not saved, the protection cannot be enforced", nil, nil
+	}
+
+	destroyed, err := getDestroyedResources(plan[planJSONKey])
+
+	if err != nil {
+		return err.Error(), nil, nil
+	}
+
+	if protection.PreventDestroy && len(destroyed) > 0 {
+		return fmt.Sprintf("the plan destroys %d resources and destroying resources is prevented", len(destroyed)), destroyed, nil
+	}
+
+	if protection.MaxDestroyCount != nil && int32(len(destroyed)) > *protection.MaxDestroyCount {
+		return fmt.Sprintf("the plan destroys %d resources, more than the maximum of %d", len(destroyed), *protection.MaxDestroyCount), destroyed, nil
+	}
+
+	return "", nil, nil
+}
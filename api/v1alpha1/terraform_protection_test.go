@@ -0,0 +1,150 @@
+package v1alpha1
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform Protection", func() {
+	planJSON := `{
+  "resource_changes": [
+    {"address": "aws_db_instance.main", "change": {"actions": ["delete"]}},
+    {"address": "aws_instance.web", "change": {"actions": ["create", "delete"]}},
+    {"address": "aws_s3_bucket.logs", "change": {"actions": ["update"]}}
+  ]
+}`
+
+	newRun := func(name string, protection *Protection) *Terraform {
+		return &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+				Protection: protection,
+			},
+			Status: TerraformStatus{
+				RunID: "abc123",
+			},
+		}
+	}
+
+	savePlan := func(run *Terraform, data string) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      getPlanChunkSecretName(run.Name, run.Status.RunID, 0),
+				Namespace: run.Namespace,
+			},
+			Data: map[string][]byte{planJSONKey: []byte(data)},
+		}
+
+		_, err := kube.ClientSet.CoreV1().Secrets(run.Namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	Context("Destroyed Resources", func() {
+		It("should list the destroyed and replaced resources", func() {
+			destroyed, err := getDestroyedResources([]byte(planJSON))
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(destroyed).To(Equal([]string{"aws_db_instance.main", "aws_instance.web"}))
+		})
+
+		It("should run the plan in a separate job when a protection is set", func() {
+			Expect(newRun("protection-none", nil).usesSeparatePlan()).To(BeFalse())
+			Expect(newRun("protection-set", &Protection{PreventDestroy: true}).usesSeparatePlan()).To(BeTrue())
+		})
+	})
+
+	Context("Protection", func() {
+		It("should block a plan that destroys resources if destroying is prevented", func() {
+			run := newRun("protection-prevent", &Protection{PreventDestroy: true})
+			savePlan(run, planJSON)
+
+			message, resources, err := run.CheckProtection(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message).To(ContainSubstring("destroying resources is prevented"))
+			Expect(resources).To(ConsistOf("aws_db_instance.main", "aws_instance.web"))
+		})
+
+		It("should block a plan that destroys more resources than the maximum", func() {
+			max := int32(1)
+			run := newRun("protection-max", &Protection{MaxDestroyCount: &max})
+			savePlan(run, planJSON)
+
+			message, resources, err := run.CheckProtection(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message).To(ContainSubstring("more than the maximum of 1"))
+			Expect(resources).To(HaveLen(2))
+		})
+
+		It("should allow a plan within the maximum", func() {
+			max := int32(2)
+			run := newRun("protection-allowed", &Protection{MaxDestroyCount: &max})
+			savePlan(run, planJSON)
+
+			message, resources, err := run.CheckProtection(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message).To(BeEmpty())
+			Expect(resources).To(BeNil())
+		})
+
+		It("should block if the plan was not saved", func() {
+			run := newRun("protection-missing", &Protection{PreventDestroy: true})
+
+			message, _, err := run.CheckProtection(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message).To(ContainSubstring("the plan was not saved"))
+		})
+
+		It("should block a plan that increases the cost by more than the maximum", func() {
+			run := newRun("protection-cost", &Protection{MaxMonthlyCostDelta: "50"})
+			run.Status.LastPlan = &PlanSummary{RunID: "abc123", Cost: &CostEstimate{MonthlyCostDelta: "75.25"}}
+
+			message, _, err := run.CheckProtection(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message).To(ContainSubstring("increases the monthly cost by 75.25"))
+
+			run.Status.LastPlan.Cost.MonthlyCostDelta = "-10"
+
+			message, _, err = run.CheckProtection(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message).To(BeEmpty())
+		})
+
+		It("should block a plan whose cost was not estimated", func() {
+			run := newRun("protection-no-cost", &Protection{MaxMonthlyCostDelta: "50"})
+			run.Status.LastPlan = &PlanSummary{RunID: "previous", Cost: &CostEstimate{MonthlyCostDelta: "0"}}
+
+			message, _, err := run.CheckProtection(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message).To(ContainSubstring("the cost of the plan was not estimated"))
+		})
+
+		It("should not check the plan without a protection", func() {
+			run := newRun("protection-empty", &Protection{})
+
+			message, _, err := run.CheckProtection(context.Background())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(message).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,120 @@
+package v1alpha1
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetryInitialDelay = 30 * time.Second
+	defaultRetryMaxDelay     = 10 * time.Minute
+)
+
+// failurePatterns holds the lowercase log patterns that classify a failed run
+var failurePatterns = []struct {
+	reason   FailureReason
+	patterns []string
+}{
+	{
+		reason:   FailureStateLock,
+		patterns: []string{"error acquiring the state lock", "error locking state", "error releasing the state lock"},
+	},
+	{
+		reason:   FailureThrottling,
+		patterns: []string{"throttl", "rate exceeded", "too many requests", "toomanyrequests", "requestlimitexceeded"},
+	},
+}
+
+// GetFailureReason classifies the failure of a run from the termination message of the Terraform Runner
+func GetFailureReason(message string) FailureReason {
+	lower := strings.ToLower(message)
+
+	for _, f := range failurePatterns {
+		for _, p := range f.patterns {
+			if strings.Contains(lower, p) {
+				return f.reason
+			}
+		}
+	}
+
+	return FailureError
+}
+
+// SetAttempt sets the attempt of a new run, a retry increments the attempt while
+// a new generation, a requested run or a first run starts over
+func (t *Terraform) SetAttempt() {
+	if t.Status.Attempt == 0 || t.Generation != t.Status.ObservedGeneration || t.IsRequested() {
+		t.Status.Attempt = 1
+	} else if t.IsRetrying() {
+		t.Status.Attempt++
+	}
+
+	t.Status.NextRetryTime = ""
+}
+
+// ShouldRetry evaluates if a failed run should be retried based on the retry policy, a failed hook is not
+// retried unless the policy lists it
+func (t *Terraform) ShouldRetry(reason FailureReason) bool {
+	policy := t.Spec.RetryPolicy
+
+	if policy == nil || t.Status.Attempt >= policy.MaxAttempts {
+		return false
+	}
+
+	// a failed hook is only retried if asked for, a retry runs the apply or the destroy again
+	if len(policy.RetryOn) == 0 {
+		return reason != FailureHook
+	}
+
+	for _, r := range policy.RetryOn {
+		if r == reason {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetRetryDelay returns the backoff before the next attempt, the initial delay is doubled
+// on every attempt up to the maximum delay
+func (t *Terraform) GetRetryDelay() time.Duration {
+	initialDelay := defaultRetryInitialDelay
+	maxDelay := defaultRetryMaxDelay
+
+	if policy := t.Spec.RetryPolicy; policy != nil {
+		if policy.InitialDelay != nil {
+			initialDelay = policy.InitialDelay.Duration
+		}
+
+		if policy.MaxDelay != nil {
+			maxDelay = policy.MaxDelay.Duration
+		}
+	}
+
+	delay := initialDelay
+
+	for i := int32(1); i < t.Status.Attempt; i++ {
+		delay *= 2
+
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+
+	if delay > maxDelay {
+		return maxDelay
+	}
+
+	return delay
+}
+
+// GetRetryRemaining returns the remaining time until the next attempt
+func (t *Terraform) GetRetryRemaining() time.Duration {
+	next, err := time.Parse(time.UnixDate, t.Status.NextRetryTime)
+
+	if err != nil {
+		return 0
+	}
+
+	return time.Until(next)
+}
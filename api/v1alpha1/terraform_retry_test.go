@@ -0,0 +1,170 @@
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform Retry", func() {
+	Context("Failure Reason", func() {
+		It("should classify a state lock error", func() {
+			Expect(GetFailureReason("Error: Error acquiring the state lock")).To(Equal(FailureStateLock))
+		})
+
+		It("should classify a provider throttling error", func() {
+			Expect(GetFailureReason("Error: creating EC2 Instance: ThrottlingException: Rate exceeded")).To(Equal(FailureThrottling))
+			Expect(GetFailureReason("googleapi: Error 429: Too Many Requests")).To(Equal(FailureThrottling))
+		})
+
+		It("should fallback to a generic error", func() {
+			Expect(GetFailureReason("Error: Invalid reference")).To(Equal(FailureError))
+			Expect(GetFailureReason("")).To(Equal(FailureError))
+		})
+	})
+
+	Context("Attempts", func() {
+		It("should start with the first attempt", func() {
+			run := &Terraform{}
+			run.SetAttempt()
+
+			Expect(run.Status.Attempt).To(Equal(int32(1)))
+		})
+
+		It("should increment the attempt of a retry", func() {
+			run := &Terraform{
+				Status: TerraformStatus{
+					Attempt:       1,
+					RunStatus:     RunRetrying,
+					NextRetryTime: time.Now().Format(time.UnixDate),
+				},
+			}
+			run.SetAttempt()
+
+			Expect(run.Status.Attempt).To(Equal(int32(2)))
+			Expect(run.Status.NextRetryTime).To(BeEmpty())
+		})
+
+		It("should start over when a new run is requested", func() {
+			run := &Terraform{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						RequestedAtAnnotation: "now",
+					},
+				},
+				Status: TerraformStatus{
+					Attempt:   2,
+					RunStatus: RunRetrying,
+				},
+			}
+			run.SetAttempt()
+
+			Expect(run.Status.Attempt).To(Equal(int32(1)))
+		})
+
+		It("should start over when the spec is updated", func() {
+			run := &Terraform{
+				ObjectMeta: metav1.ObjectMeta{
+					Generation: 2,
+				},
+				Status: TerraformStatus{
+					Attempt:            3,
+					RunStatus:          RunRetrying,
+					ObservedGeneration: 1,
+				},
+			}
+			run.SetAttempt()
+
+			Expect(run.Status.Attempt).To(Equal(int32(1)))
+		})
+	})
+
+	Context("Retry Policy", func() {
+		It("should not retry without a retry policy", func() {
+			run := &Terraform{Status: TerraformStatus{Attempt: 1}}
+
+			Expect(run.ShouldRetry(FailureError)).To(BeFalse())
+		})
+
+		It("should retry until the max attempts", func() {
+			run := &Terraform{
+				Spec: TerraformSpec{
+					RetryPolicy: &RetryPolicy{MaxAttempts: 2},
+				},
+				Status: TerraformStatus{Attempt: 1},
+			}
+
+			Expect(run.ShouldRetry(FailureError)).To(BeTrue())
+
+			run.Status.Attempt = 2
+
+			Expect(run.ShouldRetry(FailureError)).To(BeFalse())
+		})
+
+		It("should only retry on the configured reasons", func() {
+			run := &Terraform{
+				Spec: TerraformSpec{
+					RetryPolicy: &RetryPolicy{
+						MaxAttempts: 3,
+						RetryOn:     []FailureReason{FailureStateLock, FailureThrottling},
+					},
+				},
+				Status: TerraformStatus{Attempt: 1},
+			}
+
+			Expect(run.ShouldRetry(FailureStateLock)).To(BeTrue())
+			Expect(run.ShouldRetry(FailureError)).To(BeFalse())
+		})
+
+		It("should only retry a failed hook if configured", func() {
+			run := &Terraform{
+				Spec: TerraformSpec{
+					RetryPolicy: &RetryPolicy{MaxAttempts: 3},
+				},
+				Status: TerraformStatus{Attempt: 1},
+			}
+
+			Expect(run.ShouldRetry(FailureError)).To(BeTrue())
+			Expect(run.ShouldRetry(FailureHook)).To(BeFalse())
+
+			run.Spec.RetryPolicy.RetryOn = []FailureReason{FailureHook}
+
+			Expect(run.ShouldRetry(FailureHook)).To(BeTrue())
+		})
+
+		It("should compute an exponential backoff capped at the max delay", func() {
+			run := &Terraform{
+				Spec: TerraformSpec{
+					RetryPolicy: &RetryPolicy{
+						MaxAttempts:  10,
+						InitialDelay: &metav1.Duration{Duration: 10 * time.Second},
+						MaxDelay:     &metav1.Duration{Duration: time.Minute},
+					},
+				},
+			}
+
+			run.Status.Attempt = 1
+			Expect(run.GetRetryDelay()).To(Equal(10 * time.Second))
+
+			run.Status.Attempt = 3
+			Expect(run.GetRetryDelay()).To(Equal(40 * time.Second))
+
+			run.Status.Attempt = 5
+			Expect(run.GetRetryDelay()).To(Equal(time.Minute))
+		})
+
+		It("should use the default delays", func() {
+			run := &Terraform{
+				Spec: TerraformSpec{
+					RetryPolicy: &RetryPolicy{MaxAttempts: 2},
+				},
+				Status: TerraformStatus{Attempt: 1},
+			}
+
+			Expect(run.GetRetryDelay()).To(Equal(defaultRetryInitialDelay))
+		})
+	})
+})
@@ -0,0 +1,110 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// moduleAddressPrefix prefixes the addresses of the source Terraform module in the generated module
+const moduleAddressPrefix string = "module.operator."
+
+// getModuleAddresses returns the addresses of the source Terraform module as addresses of the generated module
+func getModuleAddresses(addresses []string) string {
+	prefixed := []string{}
+
+	for _, address := range addresses {
+		prefixed = append(prefixed, moduleAddressPrefix+address)
+	}
+
+	return strings.Join(prefixed, ",")
+}
+
+// parseRunOptions parses and validates the value of the run-options annotation
+func parseRunOptions(value string) (*RunOptions, error) {
+	options := &RunOptions{}
+
+	if err := json.Unmarshal([]byte(value), options); err != nil {
+		return nil, fmt.Errorf("invalid run options: %w", err)
+	}
+
+	if options.RefreshOnly && len(options.Replace) > 0 {
+		return nil, fmt.Errorf("invalid run options: replace cannot be used with refreshOnly")
+	}
+
+	for _, address := range append(options.Targets, options.Replace...) {
+		if strings.TrimSpace(address) == "" || strings.Contains(address, ",") {
+			return nil, fmt.Errorf("invalid run options: invalid address '%s'", address)
+		}
+	}
+
+	return options, nil
+}
+
+// SetRunOptions sets the options of a new run from the run-options annotation. The options only apply to
+// the run requested with the requested-at annotation, they are kept while the run waits or is retried
+func (t *Terraform) SetRunOptions() error {
+	if t.IsWaiting() || t.IsWaitingForLock() || t.IsRetrying() {
+		return nil
+	}
+
+	t.Status.RunOptions = nil
+
+	value := t.GetAnnotations()[RunOptionsAnnotation]
+
+	if !t.IsRequested() || value == "" {
+		return nil
+	}
+
+	options, err := parseRunOptions(value)
+
+	if err != nil {
+		return err
+	}
+
+	t.Status.RunOptions = options
+
+	return nil
+}
+
+// getRunOptionsEnvVars returns the environment variables of the run options for the Terraform Runner
+func (t *Terraform) getRunOptionsEnvVars() []corev1.EnvVar {
+	envVars := []corev1.EnvVar{}
+	options := t.Status.RunOptions
+
+	if options == nil {
+		return envVars
+	}
+
+	if len(options.Targets) > 0 {
+		envVars = append(envVars, getEnvVariable("TERRAFORM_TARGETS", getModuleAddresses(options.Targets)))
+	}
+
+	if len(options.Replace) > 0 {
+		envVars = append(envVars, getEnvVariable("TERRAFORM_REPLACE", getModuleAddresses(options.Replace)))
+	}
+
+	if options.RefreshOnly {
+		envVars = append(envVars, getEnvVariable("TERRAFORM_REFRESH_ONLY", strconv.FormatBool(options.RefreshOnly)))
+	}
+
+	return envVars
+}
+
+// getRunOptionsAnnotations returns the annotations that record the run options on the Jobs of the run
+func (t *Terraform) getRunOptionsAnnotations() map[string]string {
+	if t.Status.RunOptions == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(t.Status.RunOptions)
+
+	if err != nil {
+		return nil
+	}
+
+	return map[string]string{RunOptionsAnnotation: string(data)}
+}
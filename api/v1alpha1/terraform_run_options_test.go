@@ -0,0 +1,87 @@
+package v1alpha1
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform Run Options", func() {
+	newRun := func(name string, options string) *Terraform {
+		return &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					RequestedAtAnnotation: "2022-08-01T10:00:00Z",
+					RunOptionsAnnotation:  options,
+				},
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+			},
+			Status: TerraformStatus{
+				RunID:     "abc123",
+				RunStatus: RunCompleted,
+			},
+		}
+	}
+
+	Context("Run Options", func() {
+		It("should set the options of a requested run", func() {
+			run := newRun("options-requested", `{"targets": ["aws_instance.web"], "replace": ["aws_instance.web"]}`)
+
+			Expect(run.SetRunOptions()).To(Succeed())
+			Expect(run.Status.RunOptions).To(Equal(&RunOptions{
+				Targets: []string{"aws_instance.web"},
+				Replace: []string{"aws_instance.web"},
+			}))
+		})
+
+		It("should not apply the options to a run that was not requested", func() {
+			run := newRun("options-handled", `{"refreshOnly": true}`)
+			run.Status.LastHandledRequestedAt = run.GetRequestedAt()
+			run.Status.RunOptions = &RunOptions{RefreshOnly: true}
+
+			Expect(run.SetRunOptions()).To(Succeed())
+			Expect(run.Status.RunOptions).To(BeNil())
+		})
+
+		It("should keep the options of a run that is retried", func() {
+			run := newRun("options-retry", `{"refreshOnly": true}`)
+			run.Status.LastHandledRequestedAt = run.GetRequestedAt()
+			run.Status.RunStatus = RunRetrying
+			run.Status.RunOptions = &RunOptions{RefreshOnly: true}
+
+			Expect(run.SetRunOptions()).To(Succeed())
+			Expect(run.Status.RunOptions.RefreshOnly).To(BeTrue())
+		})
+
+		It("should refuse invalid options", func() {
+			Expect(newRun("options-json", `targets`).SetRunOptions()).ToNot(Succeed())
+			Expect(newRun("options-refresh", `{"replace": ["aws_instance.web"], "refreshOnly": true}`).SetRunOptions()).ToNot(Succeed())
+			Expect(newRun("options-address", `{"targets": [""]}`).SetRunOptions()).ToNot(Succeed())
+		})
+
+		It("should pass the options to the terraform runner and record them on the job", func() {
+			run := newRun("options-job", `{"targets": ["aws_instance.web", "aws_s3_bucket.logs"], "refreshOnly": true}`)
+			Expect(run.SetRunOptions()).To(Succeed())
+
+			job, err := run.CreateTerraformRun(context.Background(), types.NamespacedName{Name: run.Name, Namespace: run.Namespace})
+			Expect(err).ToNot(HaveOccurred())
+
+			env := job.Spec.Template.Spec.Containers[0].Env
+
+			Expect(env).To(ContainElement(getEnvVariable("TERRAFORM_TARGETS", "module.operator.aws_instance.web,module.operator.aws_s3_bucket.logs")))
+			Expect(env).To(ContainElement(getEnvVariable("TERRAFORM_REFRESH_ONLY", "true")))
+			Expect(job.Annotations[RunOptionsAnnotation]).To(Equal(`{"targets":["aws_instance.web","aws_s3_bucket.logs"],"refreshOnly":true}`))
+		})
+	})
+})
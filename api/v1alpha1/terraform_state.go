@@ -0,0 +1,279 @@
+package v1alpha1
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	"github.com/kuptan/terraform-operator/internal/utils"
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StateIdentityAnnotation is set on the state lease for troubleshooting, it holds the state identity the lease is for
+const StateIdentityAnnotation = "run.terraform-operator.io/state-identity"
+
+var (
+	// backendTypePattern matches the type of the backend block
+	backendTypePattern = regexp.MustCompile(`backend\s+"([^"]+)"`)
+	// backendAttrPattern matches the string attributes of the backend block
+	backendAttrPattern = regexp.MustCompile(`(?m)^\s*([A-Za-z0-9_]+)\s*=\s*"([^"]*)"`)
+)
+
+// stateIdentityKeys are the backend attributes that locate the state, backends that are not listed
+// are identified by all of their attributes
+var stateIdentityKeys = map[string][]string{
+	"s3":         {"bucket", "key", "workspace_key_prefix"},
+	"gcs":        {"bucket", "prefix"},
+	"azurerm":    {"storage_account_name", "container_name", "key"},
+	"kubernetes": {"secret_suffix", "namespace"},
+	"consul":     {"address", "path"},
+	"pg":         {"conn_str", "schema_name"},
+	"http":       {"address"},
+	"remote":     {"organization", "name", "prefix"},
+}
+
+// StateLockHolderFunc evaluates if the Terraform object holding a state lease is still running against the state
+// +kubebuilder:object:generate=false
+type StateLockHolderFunc func(ctx context.Context, namespace string, name string) (bool, error)
+
+// parseBackend returns the type and the string attributes of a backend configuration
+func parseBackend(backend string) (string, map[string]string) {
+	backendType := "local"
+
+	if match := backendTypePattern.FindStringSubmatch(backend); len(match) > 1 {
+		backendType = match[1]
+	}
+
+	attrs := map[string]string{}
+
+	for _, match := range backendAttrPattern.FindAllStringSubmatch(backend, -1) {
+		attrs[match[1]] = match[2]
+	}
+
+	return backendType, attrs
+}
+
+// GetStateIdentity returns the identity of the state the run reads and writes, it is made of the backend type,
+// the backend attributes that locate the state and the workspace
+func (t *Terraform) GetStateIdentity() string {
+	backend := t.Spec.Backend
+
+	if backend == "" {
+		backend = getDefaultBackendCfg(t)
+	}
+
+	backendType, attrs := parseBackend(backend)
+
+	var keys []string
+
+	switch backendType {
+	case "local":
+		// the local state lives in the runner pod, it is never shared with another object
+		attrs = map[string]string{"object": fmt.Sprintf("%s/%s", t.Namespace, t.Name)}
+		keys = []string{"object"}
+	case "kubernetes":
+		if attrs["namespace"] == "" {
+			attrs["namespace"] = "default"
+		}
+
+		keys = stateIdentityKeys[backendType]
+	default:
+		if known, ok := stateIdentityKeys[backendType]; ok {
+			keys = known
+		} else {
+			for key := range attrs {
+				keys = append(keys, key)
+			}
+
+			sort.Strings(keys)
+		}
+	}
+
+	parts := []string{}
+
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", key, attrs[key]))
+	}
+
+	return fmt.Sprintf("%s:%s;workspace=%s", backendType, strings.Join(parts, ","), t.getWorkspace())
+}
+
+// getStateLeaseName returns the name of the lease that serializes the runs against a state
+func getStateLeaseName(identity string) string {
+	return fmt.Sprintf("tfstate-%x", sha256.Sum256([]byte(identity)))[:24]
+}
+
+// getStateLeaseNamespace returns the namespace the state leases are created in, defaults to `default`
+func getStateLeaseNamespace() string {
+	if utils.Env == nil || utils.Env.OperatorNamespace == "" {
+		return "default"
+	}
+
+	return utils.Env.OperatorNamespace
+}
+
+// getStateLockHolder returns the holder identity of the Terraform object
+func (t *Terraform) getStateLockHolder() string {
+	return fmt.Sprintf("%s/%s", t.Namespace, t.Name)
+}
+
+// AcquireStateLock takes the lease of the state the run targets. A lease that is held by another object is only
+// taken over if the holder is no longer running against the state. The holder is returned if the lease is taken
+func (t *Terraform) AcquireStateLock(ctx context.Context, isHolderActive StateLockHolderFunc) (string, error) {
+	identity := t.GetStateIdentity()
+	leaseName := getStateLeaseName(identity)
+	namespace := getStateLeaseNamespace()
+	holder := t.getStateLockHolder()
+	now := metav1.NewMicroTime(time.Now())
+
+	// the backend or the workspace changed since the previous run
+	if t.Status.StateLease != "" && t.Status.StateLease != leaseName {
+		if err := t.ReleaseStateLock(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	lease, err := kube.ClientSet.CoordinationV1().Leases(namespace).Get(ctx, leaseName, metav1.GetOptions{})
+
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return "", err
+		}
+
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        leaseName,
+				Namespace:   namespace,
+				Labels:      map[string]string{"app.kubernetes.io/managed-by": "terraform-operator"},
+				Annotations: map[string]string{StateIdentityAnnotation: identity},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity: &holder,
+				AcquireTime:    &now,
+				RenewTime:      &now,
+			},
+		}
+
+		if _, err := kube.ClientSet.CoordinationV1().Leases(namespace).Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+			return "", err
+		}
+
+		t.Status.StateLease = leaseName
+
+		return "", nil
+	}
+
+	current := ""
+
+	if lease.Spec.HolderIdentity != nil {
+		current = *lease.Spec.HolderIdentity
+	}
+
+	if current != "" && current != holder {
+		parts := strings.SplitN(current, "/", 2)
+
+		if len(parts) == 2 {
+			active, err := isHolderActive(ctx, parts[0], parts[1])
+
+			if err != nil {
+				return "", err
+			}
+
+			if active {
+				return current, nil
+			}
+		}
+	}
+
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+
+	if _, err := kube.ClientSet.CoordinationV1().Leases(namespace).Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		return "", err
+	}
+
+	t.Status.StateLease = leaseName
+
+	return "", nil
+}
+
+// isJobFinished evaluates if the Job completed or failed
+func isJobFinished(job *batchv1.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasActiveJob evaluates if a Job of the current run is still running against the state, a Job runs until it
+// completed or failed and the pods of the run exited
+func (t *Terraform) HasActiveJob(ctx context.Context) (bool, error) {
+	if t.Status.RunID == "" {
+		return false, nil
+	}
+
+	jobs, err := listJobsForRun(ctx, t.Name, t.Namespace)
+
+	if err != nil {
+		return false, err
+	}
+
+	for i := range jobs {
+		if jobs[i].Labels["terraformRunId"] == t.Status.RunID && !isJobFinished(&jobs[i]) {
+			return true, nil
+		}
+	}
+
+	terminated, err := t.IsRunnerTerminated(ctx)
+
+	if err != nil {
+		return false, err
+	}
+
+	return !terminated, nil
+}
+
+// ReleaseStateLock deletes the lease of the state held by the run, a lease that was taken over by another
+// object is left as is
+func (t *Terraform) ReleaseStateLock(ctx context.Context) error {
+	if t.Status.StateLease == "" {
+		return nil
+	}
+
+	namespace := getStateLeaseNamespace()
+
+	lease, err := kube.ClientSet.CoordinationV1().Leases(namespace).Get(ctx, t.Status.StateLease, metav1.GetOptions{})
+
+	if err != nil {
+		if errors.IsNotFound(err) {
+			t.Status.StateLease = ""
+
+			return nil
+		}
+
+		return err
+	}
+
+	if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity == t.getStateLockHolder() {
+		if err := kube.ClientSet.CoordinationV1().Leases(namespace).Delete(ctx, lease.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	t.Status.StateLease = ""
+
+	return nil
+}
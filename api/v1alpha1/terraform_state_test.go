@@ -0,0 +1,201 @@
+package v1alpha1
+
+import (
+	"context"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kuptan/terraform-operator/internal/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform State", func() {
+	newRun := func(name string, backend string) *Terraform {
+		return &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Backend:          backend,
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+			},
+		}
+	}
+
+	s3Backend := func(key string) string {
+		return `backend "s3" {
+  bucket = "terraform-states"
+  key    = "` + key + `"
+  region = "eu-west-1"
+}`
+	}
+
+	isActive := func(active bool) StateLockHolderFunc {
+		return func(ctx context.Context, namespace string, name string) (bool, error) {
+			return active, nil
+		}
+	}
+
+	Context("State Identity", func() {
+		It("should identify the state by the backend type, the state location and the workspace", func() {
+			run := newRun("state-identity", s3Backend("network.tfstate"))
+
+			Expect(run.GetStateIdentity()).To(Equal("s3:bucket=terraform-states,key=network.tfstate,workspace_key_prefix=;workspace=default"))
+		})
+
+		It("should ignore the backend attributes that do not locate the state", func() {
+			first := newRun("first", s3Backend("network.tfstate"))
+			second := newRun("second", `backend "s3" {
+  bucket  = "terraform-states"
+  key     = "network.tfstate"
+  region  = "us-east-1"
+  encrypt = "true"
+}`)
+
+			Expect(first.GetStateIdentity()).To(Equal(second.GetStateIdentity()))
+		})
+
+		It("should tell apart the states of different workspaces", func() {
+			first := newRun("first", s3Backend("network.tfstate"))
+			second := newRun("second", s3Backend("network.tfstate"))
+			second.Spec.Workspace = "staging"
+
+			Expect(first.GetStateIdentity()).ToNot(Equal(second.GetStateIdentity()))
+		})
+
+		It("should identify the default backend by the object", func() {
+			first := newRun("first", "")
+			second := newRun("second", "")
+
+			Expect(first.GetStateIdentity()).To(Equal("kubernetes:secret_suffix=first,namespace=default;workspace=default"))
+			Expect(first.GetStateIdentity()).ToNot(Equal(second.GetStateIdentity()))
+		})
+
+		It("should never share the local state", func() {
+			first := newRun("first", `backend "local" {}`)
+			second := newRun("second", `backend "local" {}`)
+
+			Expect(first.GetStateIdentity()).ToNot(Equal(second.GetStateIdentity()))
+		})
+
+		It("should identify an unknown backend by all of its attributes", func() {
+			run := newRun("unknown", `backend "custom" {
+  url  = "https://example.com"
+  name = "state"
+}`)
+
+			Expect(run.GetStateIdentity()).To(Equal("custom:name=state,url=https://example.com;workspace=default"))
+		})
+	})
+
+	Context("State Lock", func() {
+		It("should acquire and release the state lease", func() {
+			run := newRun("state-lock", s3Backend("state-lock.tfstate"))
+			leaseName := getStateLeaseName(run.GetStateIdentity())
+
+			holder, err := run.AcquireStateLock(context.Background(), isActive(true))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(holder).To(BeEmpty())
+			Expect(run.Status.StateLease).To(Equal(leaseName))
+
+			lease, err := kube.ClientSet.CoordinationV1().Leases("default").Get(context.Background(), leaseName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*lease.Spec.HolderIdentity).To(Equal("default/state-lock"))
+			Expect(lease.Annotations[StateIdentityAnnotation]).To(Equal(run.GetStateIdentity()))
+
+			Expect(run.ReleaseStateLock(context.Background())).ToNot(HaveOccurred())
+			Expect(run.Status.StateLease).To(BeEmpty())
+
+			_, err = kube.ClientSet.CoordinationV1().Leases("default").Get(context.Background(), leaseName, metav1.GetOptions{})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return the holder if the state is used by an active run", func() {
+			first := newRun("state-first", s3Backend("state-shared.tfstate"))
+			second := newRun("state-second", s3Backend("state-shared.tfstate"))
+
+			_, err := first.AcquireStateLock(context.Background(), isActive(true))
+			Expect(err).ToNot(HaveOccurred())
+
+			holder, err := second.AcquireStateLock(context.Background(), isActive(true))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(holder).To(Equal("default/state-first"))
+			Expect(second.Status.StateLease).To(BeEmpty())
+
+			Expect(second.ReleaseStateLock(context.Background())).ToNot(HaveOccurred())
+			Expect(first.ReleaseStateLock(context.Background())).ToNot(HaveOccurred())
+		})
+
+		It("should take over the state lease of a holder that is no longer active", func() {
+			first := newRun("state-stale", s3Backend("state-stale.tfstate"))
+			second := newRun("state-next", s3Backend("state-stale.tfstate"))
+
+			_, err := first.AcquireStateLock(context.Background(), isActive(true))
+			Expect(err).ToNot(HaveOccurred())
+
+			holder, err := second.AcquireStateLock(context.Background(), isActive(false))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(holder).To(BeEmpty())
+
+			// the previous holder does not release a lease it lost
+			Expect(first.ReleaseStateLock(context.Background())).ToNot(HaveOccurred())
+
+			lease, err := kube.ClientSet.CoordinationV1().Leases("default").Get(context.Background(), second.Status.StateLease, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*lease.Spec.HolderIdentity).To(Equal("default/state-next"))
+
+			Expect(second.ReleaseStateLock(context.Background())).ToNot(HaveOccurred())
+		})
+
+		It("should release the previous lease if the state changed", func() {
+			run := newRun("state-moved", s3Backend("state-before.tfstate"))
+
+			_, err := run.AcquireStateLock(context.Background(), isActive(true))
+			Expect(err).ToNot(HaveOccurred())
+
+			previous := run.Status.StateLease
+			run.Spec.Backend = s3Backend("state-after.tfstate")
+
+			_, err = run.AcquireStateLock(context.Background(), isActive(true))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(run.Status.StateLease).ToNot(Equal(previous))
+
+			_, err = kube.ClientSet.CoordinationV1().Leases("default").Get(context.Background(), previous, metav1.GetOptions{})
+			Expect(err).To(HaveOccurred())
+
+			Expect(run.ReleaseStateLock(context.Background())).ToNot(HaveOccurred())
+		})
+
+		It("should report a holder with a running job as active", func() {
+			run := newRun("state-job", s3Backend("state-job.tfstate"))
+			run.Status.RunID = "abc123"
+
+			active, err := run.HasActiveJob(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(active).To(BeFalse())
+
+			job, err := createJobForRun(context.Background(), run)
+			Expect(err).ToNot(HaveOccurred())
+
+			active, err = run.HasActiveJob(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(active).To(BeTrue())
+
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+
+			_, err = kube.ClientSet.BatchV1().Jobs(run.Namespace).UpdateStatus(context.Background(), job, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			active, err = run.HasActiveJob(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(active).To(BeFalse())
+		})
+	})
+})
@@ -2,17 +2,29 @@ package v1alpha1
 
 import (
 	"bytes"
+	"strconv"
+	"strings"
 	"text/template"
 )
 
-// getTerraformModuleFromTemplate generates the Terraform module template
+// quoteHCL returns a quoted HCL string literal, the template sequences are escaped so the value is taken as is
+func quoteHCL(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = strings.ReplaceAll(quoted, "${", "$${")
+	quoted = strings.ReplaceAll(quoted, "%{", "%%{")
+
+	return quoted
+}
+
+// getTerraformModuleFromTemplate generates the Terraform module template, the import, moved and removed
+// blocks address the resources of the source module under `module.operator`
 func getTerraformModuleFromTemplate(run *Terraform) ([]byte, error) {
-	tfTemplate, err := template.New("main.tf").Parse(`terraform {
+	tfTemplate, err := template.New("main.tf").Funcs(template.FuncMap{"quote": quoteHCL}).Parse(`terraform {
 		{{- if .Spec.Backend }}
 		{{.Spec.Backend}}
 		{{- end}}
 	
-		required_version = "~> {{.Spec.TerraformVersion}}"
+		required_version = "{{.GetVersionConstraint}}"
 	}
 
 	{{- if .Spec.ProvidersConfig }}
@@ -26,6 +38,29 @@ func getTerraformModuleFromTemplate(run *Terraform) ([]byte, error) {
 	{{- end}}
 	
 	## additional-blocks
+	{{- range .Spec.Imports}}
+	import {
+		to = module.operator.{{.Address}}
+		id = {{quote .ID}}
+	}
+	{{- end}}
+
+	{{- range .Spec.Moved}}
+	moved {
+		from = module.operator.{{.From}}
+		to   = module.operator.{{.To}}
+	}
+	{{- end}}
+
+	{{- range .Spec.Removed}}
+	removed {
+		from = module.operator.{{.From}}
+
+		lifecycle {
+			destroy = {{.Destroy}}
+		}
+	}
+	{{- end}}
 	
 	module "operator" {
 		source = "{{.Spec.Module.Source}}"
@@ -8,7 +8,7 @@ import (
 var _ = Describe("Terraform Module", func() {
 	expectedFile := `terraform {
 	
-		required_version = "~> 1.0.2"
+		required_version = "1.0.2"
 	}
 	variable "length" {}
 	
@@ -47,5 +47,47 @@ var _ = Describe("Terraform Module", func() {
 
 			Expect(tplString).To(Equal(expectedFile))
 		})
+
+		It("should render the import, moved and removed blocks under the module", func() {
+			run := &Terraform{
+				Spec: TerraformSpec{
+					TerraformVersion: "1.7.0",
+					Module: Module{
+						Source: "IbraheemAlSaady/test/module",
+					},
+					Imports: []Import{
+						{Address: `aws_s3_bucket.logs["eu"]`, ID: "logs-${env}"},
+					},
+					Moved: []Moved{
+						{From: "aws_instance.web", To: "aws_instance.app"},
+					},
+					Removed: []Removed{
+						{From: "aws_iam_role.legacy"},
+					},
+				},
+			}
+
+			tpl, err := getTerraformModuleFromTemplate(run)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(tpl)).To(ContainSubstring(`## additional-blocks
+	import {
+		to = module.operator.aws_s3_bucket.logs["eu"]
+		id = "logs-$${env}"
+	}
+	moved {
+		from = module.operator.aws_instance.web
+		to   = module.operator.aws_instance.app
+	}
+	removed {
+		from = module.operator.aws_iam_role.legacy
+
+		lifecycle {
+			destroy = false
+		}
+	}
+	
+	module "operator" {`))
+		})
 	})
 })
@@ -18,11 +18,14 @@ package v1alpha1
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
@@ -30,9 +33,42 @@ import (
 // TerraformFinalizer is the finalizer name
 const TerraformFinalizer string = "finalizers.terraform-operator.io"
 
+// ConditionSuspended is the condition type that reports whether the reconciliation is suspended
+const ConditionSuspended string = "Suspended"
+
+// RequestedAtAnnotation is the annotation that requests a new run with the same spec when its value changes
+const RequestedAtAnnotation string = "run.terraform-operator.io/requested-at"
+
+// CancelRequestedAtAnnotation is the annotation that cancels the run in progress when its value changes
+const CancelRequestedAtAnnotation string = "run.terraform-operator.io/cancel-requested-at"
+
+// RunOptionsAnnotation holds the JSON options of the run requested with the requested-at annotation, the
+// options only apply to that run
+const RunOptionsAnnotation string = "run.terraform-operator.io/run-options"
+
+// PlanHashAnnotation is set on the plan Secrets, it holds the hash of the module and the inputs the plan was made with
+const PlanHashAnnotation string = "run.terraform-operator.io/plan-hash"
+
+// PlanChunksAnnotation is set by the Terraform Runner on the plan Secrets, it holds the number of chunks of the plan
+const PlanChunksAnnotation string = "run.terraform-operator.io/plan-chunks"
+
+// maxPlanChunks is the maximum number of chunks a plan is saved in, the Role of the Terraform Runner grants access
+// to the chunk Secrets by name since Kubernetes does not support restricting `get` and `update` by labels
+const maxPlanChunks int = 8
+
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// LockFile holds the Terraform dependency lock file (.terraform.lock.hcl) information
+type LockFile struct {
+	// The content of the dependency lock file
+	// +optional
+	Inline string `json:"inline,omitempty"`
+	// A ConfigMap key that holds the content of the dependency lock file
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
 // Module holds the Terraform module source and version information
 type Module struct {
 	// module source, must be a valid Terraform module source
@@ -40,6 +76,10 @@ type Module struct {
 	// module version
 	// +optional
 	Version string `json:"version,omitempty"`
+	// The dependency lock file to pin the providers versions. If not provided, the lock file
+	// captured from a previous successful run will be used
+	// +optional
+	LockFile *LockFile `json:"lockFile,omitempty"`
 }
 
 // VariableFile holds the information of the Terraform variable files to include
@@ -87,6 +127,44 @@ type Output struct {
 	ModuleOutputName string `json:"moduleOutputName"`
 }
 
+// Import holds the information of an existing resource to bring under the management of the module, it is
+// rendered as an `import` block (Terraform 1.5 or later)
+type Import struct {
+	// The address of the resource in the source Terraform module, e.g. `aws_s3_bucket.logs` or
+	// `module.vpc.aws_vpc.this["main"]`
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^(module\.[a-zA-Z_][a-zA-Z0-9_-]*(\[([0-9]+|"[^"\\$%\r\n]*")\])?\.)*[a-zA-Z_][a-zA-Z0-9_-]*\.[a-zA-Z_][a-zA-Z0-9_-]*(\[([0-9]+|"[^"\\$%\r\n]*")\])?$`
+	Address string `json:"address"`
+	// The provider specific ID of the existing resource
+	// +kubebuilder:validation:MinLength=1
+	ID string `json:"id"`
+}
+
+// Moved holds the information of a resource whose address changed in the source Terraform module, it is
+// rendered as a `moved` block (Terraform 1.1 or later)
+type Moved struct {
+	// The previous address of the resource or the module in the source Terraform module
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^(module\.[a-zA-Z_][a-zA-Z0-9_-]*(\[([0-9]+|"[^"\\$%\r\n]*")\])?\.)*(module\.[a-zA-Z_][a-zA-Z0-9_-]*|[a-zA-Z_][a-zA-Z0-9_-]*\.[a-zA-Z_][a-zA-Z0-9_-]*)(\[([0-9]+|"[^"\\$%\r\n]*")\])?$`
+	From string `json:"from"`
+	// The new address of the resource or the module in the source Terraform module
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^(module\.[a-zA-Z_][a-zA-Z0-9_-]*(\[([0-9]+|"[^"\\$%\r\n]*")\])?\.)*(module\.[a-zA-Z_][a-zA-Z0-9_-]*|[a-zA-Z_][a-zA-Z0-9_-]*\.[a-zA-Z_][a-zA-Z0-9_-]*)(\[([0-9]+|"[^"\\$%\r\n]*")\])?$`
+	To string `json:"to"`
+}
+
+// Removed holds the information of a resource removed from the source Terraform module, it is rendered as a
+// `removed` block (Terraform 1.7 or later)
+type Removed struct {
+	// The address of the removed resource or module in the source Terraform module, without instance keys
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^(module\.[a-zA-Z_][a-zA-Z0-9_-]*\.)*(module\.[a-zA-Z_][a-zA-Z0-9_-]*|[a-zA-Z_][a-zA-Z0-9_-]*\.[a-zA-Z_][a-zA-Z0-9_-]*)$`
+	From string `json:"from"`
+	// Indicates whether to destroy the resource, it's only removed from the state otherwise
+	// +optional
+	Destroy bool `json:"destroy,omitempty"`
+}
+
 // DependsOn holds the information of the Terraform dependency
 type DependsOn struct {
 	// The Terraform object metadata.name
@@ -102,6 +180,205 @@ type GitSSHKey struct {
 	ValueFrom *corev1.VolumeSource `json:"valueFrom"`
 }
 
+// FailureReason is the classified reason of a failed run
+type FailureReason string
+
+const (
+	// FailureStateLock the run failed to acquire the state lock
+	FailureStateLock FailureReason = "StateLock"
+	// FailureThrottling the run was throttled by a provider API
+	FailureThrottling FailureReason = "Throttling"
+	// FailureTimedOut the run exceeded its timeout
+	FailureTimedOut FailureReason = "TimedOut"
+	// FailureError the run failed with any other error
+	FailureError FailureReason = "Error"
+	// FailureHook a hook of the run failed
+	FailureHook FailureReason = "Hook"
+)
+
+// RetryPolicy holds the information of retrying a failed run with a new run after a backoff
+type RetryPolicy struct {
+	// The maximum number of attempts, including the first run
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts int32 `json:"maxAttempts"`
+	// The delay before the first retry, doubled on every attempt. Defaults to `30s`
+	// +optional
+	InitialDelay *metav1.Duration `json:"initialDelay,omitempty"`
+	// The maximum delay between attempts. Defaults to `10m`
+	// +optional
+	MaxDelay *metav1.Duration `json:"maxDelay,omitempty"`
+	// The failure reasons to retry on (`StateLock`, `Throttling`, `TimedOut`, `Error`, `Hook`). Defaults to all reasons except `Hook`
+	// +optional
+	RetryOn []FailureReason `json:"retryOn,omitempty"`
+}
+
+// CancelPolicy holds the information of cancelling a run in progress
+type CancelPolicy struct {
+	// The number of seconds the Terraform Runner is given to exit gracefully after SIGTERM before it is killed,
+	// maps to the pod `terminationGracePeriodSeconds`. Defaults to the pod default (30 seconds)
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"`
+	// Run `terraform force-unlock` with the recorded lock ID if the state is still locked after the run is cancelled
+	// +optional
+	ForceUnlock bool `json:"forceUnlock,omitempty"`
+}
+
+// UpdatePolicy defines how a spec update that arrives while a run is in progress is handled
+// +kubebuilder:validation:Enum=wait;cancelAndRestart
+type UpdatePolicy string
+
+const (
+	// UpdatePolicyWait queues the update until the run in progress finishes
+	UpdatePolicyWait UpdatePolicy = "wait"
+	// UpdatePolicyCancelAndRestart cancels the run in progress if it did not reach the apply, and starts
+	// a new run with the updated spec
+	UpdatePolicyCancelAndRestart UpdatePolicy = "cancelAndRestart"
+)
+
+// Protection holds the limits a plan is checked against before it is applied
+type Protection struct {
+	// Block a plan that destroys any resource, replaced resources included
+	// +optional
+	PreventDestroy bool `json:"preventDestroy,omitempty"`
+	// Block a plan that destroys more resources than the given count, replaced resources included
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxDestroyCount *int32 `json:"maxDestroyCount,omitempty"`
+	// Block a plan that increases the monthly cost by more than the given amount, the cost is estimated by a
+	// post-plan hook. A plan without a cost estimate is blocked
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	// +optional
+	MaxMonthlyCostDelta string `json:"maxMonthlyCostDelta,omitempty"`
+}
+
+// Hook is a container that runs at a point of the run lifecycle
+type Hook struct {
+	// The name of the hook, unique among the hooks of a lifecycle point. The container of the hook is prefixed
+	// with the lifecycle point
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=40
+	Name string `json:"name"`
+	// The image of the hook container
+	Image string `json:"image"`
+	// The entrypoint of the hook container
+	// +optional
+	Command []string `json:"command,omitempty"`
+	// The arguments of the entrypoint
+	// +optional
+	Args []string `json:"args,omitempty"`
+	// The environment variables of the hook container
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+}
+
+// Hooks holds the containers that run at the points of the run lifecycle, the hooks run with the module working
+// directory mounted at the path of the TERRAFORM_WORKING_DIR environment variable
+type Hooks struct {
+	// Containers that run in order before Terraform is initialized, e.g. to fetch credentials
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	PreInit []Hook `json:"preInit,omitempty"`
+	// Containers that run after the plan with the plan JSON at the path of the PLAN_JSON_PATH environment
+	// variable, e.g. to estimate the cost of the plan. A hook can report a result by writing it as JSON to
+	// its termination message. The plan runs in a separate Job when post-plan hooks are set
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	PostPlan []Hook `json:"postPlan,omitempty"`
+	// Containers that run in order before the saved plan is applied, e.g. to run tflint or checkov. The plan
+	// runs in a separate Job when pre-apply hooks are set
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	PreApply []Hook `json:"preApply,omitempty"`
+	// Containers that run after a successful apply, e.g. to notify
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	PostApply []Hook `json:"postApply,omitempty"`
+	// Containers that run after a successful destroy
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	PostDestroy []Hook `json:"postDestroy,omitempty"`
+}
+
+// PolicyRef references a ConfigMap of Rego policies a plan is checked against before it is applied
+type PolicyRef struct {
+	// The name of the ConfigMap in the namespace of the object, every key ending with '.rego' is a policy
+	Name string `json:"name"`
+}
+
+// HistoryLimit holds the number of finished runs to keep the Jobs and ConfigMaps of
+type HistoryLimit struct {
+	// The number of successful runs to keep. Defaults to 0
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Successful int32 `json:"successful,omitempty"`
+	// The number of failed runs to keep. Defaults to 0
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Failed int32 `json:"failed,omitempty"`
+}
+
+// RunnerPodMetadata holds the labels and annotations to add to the Terraform Runner pod
+type RunnerPodMetadata struct {
+	// Labels to add to the Terraform Runner pod
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations to add to the Terraform Runner pod
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// RunnerPodTemplate holds the overrides to merge onto the Terraform Runner pod
+type RunnerPodTemplate struct {
+	// Labels and annotations of the Terraform Runner pod
+	// +optional
+	Metadata RunnerPodMetadata `json:"metadata,omitempty"`
+	// Compute resources of the Terraform Runner container
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Node selector of the Terraform Runner pod
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations of the Terraform Runner pod
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Affinity of the Terraform Runner pod
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// Priority class name of the Terraform Runner pod
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// Security context of the Terraform Runner pod
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+	// Image pull secrets of the Terraform Runner pod
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// Additional volumes of the Terraform Runner pod
+	// +optional
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+	// Additional volume mounts of the Terraform Runner container
+	// +optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+	// Additional environment variables of the Terraform Runner container
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+}
+
+// TerraformEngine is the engine (binary) used to run the Terraform module
+type TerraformEngine string
+
+// supported engines
+const (
+	EngineTerraform TerraformEngine = "terraform"
+	EngineOpenTofu  TerraformEngine = "opentofu"
+)
+
 // TerraformRunStatus is the status of the workflow/run
 type TerraformRunStatus string
 
@@ -113,8 +390,96 @@ const (
 	RunFailed               TerraformRunStatus = "Failed"
 	RunWaitingForDependency TerraformRunStatus = "WaitingForDependency"
 	RunDeleted              TerraformRunStatus = "Deleted"
+	RunTimedOut             TerraformRunStatus = "TimedOut"
+	RunRetrying             TerraformRunStatus = "Retrying"
+	RunCancelling           TerraformRunStatus = "Cancelling"
+	RunCancelled            TerraformRunStatus = "Cancelled"
+	RunWaitingForLock       TerraformRunStatus = "WaitingForLock"
+	RunBlocked              TerraformRunStatus = "Blocked"
 )
 
+// RunStage is the Job of the run in progress when the plan runs in a separate Job
+type RunStage string
+
+// run stages
+const (
+	StagePlan  RunStage = "Plan"
+	StageApply RunStage = "Apply"
+)
+
+// PlanSummary holds the number of resource changes of the plan of a run
+type PlanSummary struct {
+	// The ID of the run that produced the plan
+	RunID string `json:"runId"`
+	// The name prefix of the Secrets the plan is stored in, chunks are suffixed with their index
+	SecretName string `json:"secretName"`
+	// The number of resources to add
+	Add int32 `json:"add"`
+	// The number of resources to change
+	Change int32 `json:"change"`
+	// The number of resources to destroy
+	Destroy int32 `json:"destroy"`
+	// The cost of the plan reported by a post-plan hook
+	// +optional
+	Cost *CostEstimate `json:"cost,omitempty"`
+}
+
+// CostEstimate holds the monthly cost of a plan, the amounts are decimal strings
+type CostEstimate struct {
+	// The currency of the amounts
+	// +optional
+	Currency string `json:"currency,omitempty"`
+	// The monthly cost once the plan is applied
+	// +optional
+	MonthlyCost string `json:"monthlyCost,omitempty"`
+	// The monthly cost before the plan is applied
+	// +optional
+	PastMonthlyCost string `json:"pastMonthlyCost,omitempty"`
+	// The difference of the monthly cost the plan makes
+	// +optional
+	MonthlyCostDelta string `json:"monthlyCostDelta,omitempty"`
+}
+
+// RunOptions holds the options of a single run, the addresses are relative to the source Terraform module
+type RunOptions struct {
+	// The addresses of the resources to limit the run to (`-target`)
+	// +optional
+	Targets []string `json:"targets,omitempty"`
+	// The addresses of the resources to replace (`-replace`)
+	// +optional
+	Replace []string `json:"replace,omitempty"`
+	// Indicates whether to only update the state with the real infrastructure (`-refresh-only`)
+	// +optional
+	RefreshOnly bool `json:"refreshOnly,omitempty"`
+}
+
+// CLIOptions holds the options of the Terraform CLI commands the Terraform Runner runs
+type CLIOptions struct {
+	// Limit the number of concurrent operations (`-parallelism`)
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Parallelism *int32 `json:"parallelism,omitempty"`
+	// The duration to retry acquiring the state lock for (`-lock-timeout`)
+	// +optional
+	LockTimeout *metav1.Duration `json:"lockTimeout,omitempty"`
+	// Indicates whether to refresh the state before planning (`-refresh`). Defaults to true
+	// +optional
+	Refresh *bool `json:"refresh,omitempty"`
+	// Extra arguments of `terraform init`, only the allowed flags are accepted
+	// +optional
+	InitArgs []string `json:"initArgs,omitempty"`
+	// Extra arguments of `terraform plan`, only the allowed flags are accepted
+	// +optional
+	PlanArgs []string `json:"planArgs,omitempty"`
+	// Extra arguments of `terraform apply`, only the allowed flags are accepted
+	// +optional
+	ApplyArgs []string `json:"applyArgs,omitempty"`
+	// The log level of Terraform (`TF_LOG`)
+	// +kubebuilder:validation:Enum=TRACE;DEBUG;INFO;WARN;ERROR;OFF
+	// +optional
+	LogLevel string `json:"logLevel,omitempty"`
+}
+
 // PreviousRunStatus stores the previous workflows/runs information
 // in case the current workflow/run object was modified
 type PreviousRunStatus struct {
@@ -131,7 +496,18 @@ type TerraformSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// The terraform version to use
+	// Suspends the reconciliation, no new runs are created while suspended. A run in progress
+	// is still watched and deleting the object is still handled
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// The engine used to run the module, `terraform` or `opentofu`. Defaults to `terraform`
+	// +kubebuilder:validation:Enum=terraform;opentofu
+	// +kubebuilder:default=terraform
+	// +optional
+	Engine TerraformEngine `json:"engine,omitempty"`
+	// The terraform version constraint to use, an exact version (e.g. `1.0.2`) or a version
+	// constraint expression (e.g. `>= 1.2.0, < 1.4.0`)
 	TerraformVersion string `json:"terraformVersion"`
 	// The module information (source & version)
 	Module Module `json:"module"`
@@ -144,6 +520,9 @@ type TerraformSpec struct {
 	// The terraform workspae. Defaults to `default`
 	// +optional
 	Workspace string `json:"workspace,omitempty"`
+	// Indicates whether to delete the workspace after a successful destroy, the default workspace is never deleted
+	// +optional
+	DeleteWorkspaceOnDestroy bool `json:"deleteWorkspaceOnDestroy,omitempty"`
 	// A list of dependencies on other Terraform runs
 	// +optional
 	DependsOn []*DependsOn `json:"dependsOn,omitempty"`
@@ -156,18 +535,74 @@ type TerraformSpec struct {
 	// Terraform outputs will be written to a Kubernetes secret
 	// +optional
 	Outputs []*Output `json:"outputs,omitempty"`
+	// Options of the Terraform CLI commands
+	// +optional
+	CLI *CLIOptions `json:"cli,omitempty"`
+	// Existing resources to import into the state
+	// +optional
+	Imports []Import `json:"imports,omitempty"`
+	// Resources whose address changed in the source Terraform module
+	// +optional
+	Moved []Moved `json:"moved,omitempty"`
+	// Resources removed from the source Terraform module
+	// +optional
+	Removed []Removed `json:"removed,omitempty"`
 	// Indicates whether a destroy job should run
 	// +optional
 	Destroy bool `json:"destroy,omitempty"`
+	// Run the plan in a separate Job and apply exactly the saved plan in a second Job. The apply is refused
+	// if the module or the inputs changed since planning
+	// +optional
+	SeparatePlan bool `json:"separatePlan,omitempty"`
+	// Limits the plan is checked against before it is applied, a plan that exceeds them is not applied and
+	// the run is blocked. The plan runs in a separate Job when a protection is set
+	// +optional
+	Protection *Protection `json:"protection,omitempty"`
+	// ConfigMaps of Rego policies the plan is evaluated against before it is applied, the run is blocked if
+	// any policy denies the plan. The plan runs in a separate Job when policies are set
+	// +optional
+	Policies []PolicyRef `json:"policies,omitempty"`
+	// Containers that run at the points of the run lifecycle
+	// +optional
+	Hooks *Hooks `json:"hooks,omitempty"`
 	// Indicates whether to keep the jobs/pods after the run is successful/completed
 	// +optional
 	DeleteCompletedJobs bool `json:"deleteCompletedJobs,omitempty"`
 	// A retry limit to be set on the Job as a backOffLimit
 	// +optional
 	RetryLimit int32 `json:"retryLimit,omitempty"`
+	// The number of seconds after which the Job of a finished run is deleted, maps to the Job `ttlSecondsAfterFinished`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	JobTTLSecondsAfterFinished *int32 `json:"jobTTLSecondsAfterFinished,omitempty"`
+	// The maximum duration of a run (e.g. `30m`) including its retries, maps to the Job `activeDeadlineSeconds`.
+	// A run that exceeds it is reported as `TimedOut`
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Controls how a run in progress is cancelled through the cancel-requested-at annotation
+	// +optional
+	CancelPolicy *CancelPolicy `json:"cancelPolicy,omitempty"`
+	// How a spec update that arrives while a run is in progress is handled (`wait`, `cancelAndRestart`).
+	// Defaults to `wait`
+	// +optional
+	UpdatePolicy UpdatePolicy `json:"updatePolicy,omitempty"`
+	// The number of previous runs to keep the Jobs and ConfigMaps of, the current run is always kept.
+	// Defaults to keeping none
+	// +optional
+	HistoryLimit *HistoryLimit `json:"historyLimit,omitempty"`
+	// Retry a failed run with a new run after an exponential backoff
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
 	// An SSH key to be able to pull modules from private git repositories
 	// +optional
 	GitSSHKey *GitSSHKey `json:"gitSSHKey,omitempty"`
+	// The service account of the Terraform Runner. If not provided, the `terraform-runner` service account
+	// and its RBAC will be created by the controller. A custom service account must exist in the namespace
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// Overrides to merge onto the generated Terraform Runner pod
+	// +optional
+	RunnerPodTemplate *RunnerPodTemplate `json:"runnerPodTemplate,omitempty"`
 }
 
 // TerraformStatus defines the observed state of Terraform
@@ -175,14 +610,41 @@ type TerraformStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	RunID              string             `json:"currentRunId"`
-	PreviousRunID      string             `json:"previousRunId,omitempty"`
-	OutputSecretName   string             `json:"outputSecretName,omitempty"`
-	ObservedGeneration int64              `json:"observedGeneration"`
-	RunStatus          TerraformRunStatus `json:"runStatus"`
-	Message            string             `json:"message,omitempty"`
-	StartedTime        string             `json:"startTime,omitempty"`
-	CompletionTime     string             `json:"completionTime,omitempty"`
+	RunID                  string             `json:"currentRunId"`
+	PreviousRunID          string             `json:"previousRunId,omitempty"`
+	OutputSecretName       string             `json:"outputSecretName,omitempty"`
+	LockFileConfigMap      string             `json:"lockFileConfigMap,omitempty"`
+	Engine                 TerraformEngine    `json:"engine,omitempty"`
+	TerraformVersion       string             `json:"terraformVersion,omitempty"`
+	ObservedGeneration     int64              `json:"observedGeneration"`
+	PendingGeneration      int64              `json:"pendingGeneration,omitempty"`
+	RunStatus              TerraformRunStatus `json:"runStatus"`
+	Message                string             `json:"message,omitempty"`
+	FailureReason          FailureReason      `json:"failureReason,omitempty"`
+	Attempt                int32              `json:"attempt,omitempty"`
+	NextRetryTime          string             `json:"nextRetryTime,omitempty"`
+	LastHandledRequestedAt string             `json:"lastHandledRequestedAt,omitempty"`
+	LastHandledCancelAt    string             `json:"lastHandledCancelAt,omitempty"`
+	LockID                 string             `json:"lockId,omitempty"`
+	StateLease             string             `json:"stateLease,omitempty"`
+	SpecHash               string             `json:"specHash,omitempty"`
+	StartedTime            string             `json:"startTime,omitempty"`
+	CompletionTime         string             `json:"completionTime,omitempty"`
+	LastPlan               *PlanSummary       `json:"lastPlan,omitempty"`
+	Stage                  RunStage           `json:"stage,omitempty"`
+	PlanHash               string             `json:"planHash,omitempty"`
+	BlockedResources       []string           `json:"blockedResources,omitempty"`
+	PolicyViolations       []string           `json:"policyViolations,omitempty"`
+	FailedHook             string             `json:"failedHook,omitempty"`
+	RunOptions             *RunOptions        `json:"runOptions,omitempty"`
+	Workspace              string             `json:"workspace,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 //+kubebuilder:object:root=true
@@ -191,7 +653,14 @@ type TerraformStatus struct {
 // Terraform is the Schema for the terraforms API
 // +kubebuilder:resource:shortName=tf,path=terraforms
 // +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.runStatus"
+// +kubebuilder:printcolumn:name="Suspended",type="boolean",JSONPath=".spec.suspend"
+// +kubebuilder:printcolumn:name="Engine",type="string",JSONPath=".status.engine"
+// +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".status.terraformVersion"
 // +kubebuilder:printcolumn:name="Secret",type="string",JSONPath=".status.outputSecretName"
+// +kubebuilder:printcolumn:name="Workspace",type="string",JSONPath=".status.workspace",priority=1
+// +kubebuilder:printcolumn:name="Add",type="integer",JSONPath=".status.lastPlan.add",priority=1
+// +kubebuilder:printcolumn:name="Change",type="integer",JSONPath=".status.lastPlan.change",priority=1
+// +kubebuilder:printcolumn:name="Destroy",type="integer",JSONPath=".status.lastPlan.destroy",priority=1
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type Terraform struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -210,6 +679,15 @@ type TerraformList struct {
 	Items           []Terraform `json:"items"`
 }
 
+// GetEngine returns the engine used to run the module, defaults to Terraform
+func (t *Terraform) GetEngine() TerraformEngine {
+	if t.Spec.Engine == "" {
+		return EngineTerraform
+	}
+
+	return t.Spec.Engine
+}
+
 // IsSubmitted evaluates if the workflow/run is created for the first time
 func (t *Terraform) IsSubmitted() bool {
 	return t.Status.RunID == ""
@@ -235,11 +713,52 @@ func (t *Terraform) IsUpdated() bool {
 	return t.Generation > 0 && t.Generation > t.Status.ObservedGeneration
 }
 
+// IsSuspended evaluates if the reconciliation is reported as suspended
+func (t *Terraform) IsSuspended() bool {
+	return meta.IsStatusConditionTrue(t.Status.Conditions, ConditionSuspended)
+}
+
+// GetSpecHash returns a hash of the spec, changes to fields that do not affect the run (e.g. suspend) are ignored
+func (t *Terraform) GetSpecHash() string {
+	spec := t.Spec.DeepCopy()
+	spec.Suspend = false
+
+	data, err := json.Marshal(spec)
+
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// GetRequestedAt returns the value of the requested-at annotation
+func (t *Terraform) GetRequestedAt() string {
+	return t.GetAnnotations()[RequestedAtAnnotation]
+}
+
+// IsRequested evaluates if a new run was requested through the requested-at annotation
+func (t *Terraform) IsRequested() bool {
+	requestedAt := t.GetRequestedAt()
+
+	return requestedAt != "" && requestedAt != t.Status.LastHandledRequestedAt
+}
+
 // IsWaiting evaluates if the workflow/run is waiting for a dependency
 func (t *Terraform) IsWaiting() bool {
 	return t.Status.RunStatus == RunWaitingForDependency
 }
 
+// IsWaitingForLock evaluates if the workflow/run is waiting for another object to release the state
+func (t *Terraform) IsWaitingForLock() bool {
+	return t.Status.RunStatus == RunWaitingForLock
+}
+
+// IsRetrying evaluates if the workflow/run is waiting to be retried
+func (t *Terraform) IsRetrying() bool {
+	return t.Status.RunStatus == RunRetrying
+}
+
 // HasErrored evaluates if the workflow/run failed
 func (t *Terraform) HasErrored() bool {
 	return t.Status.RunStatus == RunFailed
@@ -263,31 +782,127 @@ func (t *Terraform) GetOwnerReference() metav1.OwnerReference {
 	}
 }
 
-// setBackendCfgIfNotExist sets the default backend to Kunernetes if not provided
-func setBackendCfgIfNotExist(run *Terraform) {
-	if run.Spec.Backend == "" {
-		run.Spec.Backend = fmt.Sprintf(`backend "kubernetes" {
+// getDefaultBackendCfg returns the default Kubernetes backend configuration
+func getDefaultBackendCfg(run *Terraform) string {
+	return fmt.Sprintf(`backend "kubernetes" {
   secret_suffix     = "%s"
   in_cluster_config = true
 	namespace					= "%s"
 }
 `, run.ObjectMeta.Name, run.ObjectMeta.Namespace)
+}
+
+// setBackendCfgIfNotExist sets the default backend to Kunernetes if not provided
+func setBackendCfgIfNotExist(run *Terraform) {
+	if run.Spec.Backend == "" {
+		run.Spec.Backend = getDefaultBackendCfg(run)
+	}
+}
+
+// usesDefaultBackend evaluates if the state is stored in the default Kubernetes backend
+func (t *Terraform) usesDefaultBackend() bool {
+	return t.Spec.Backend == "" || t.Spec.Backend == getDefaultBackendCfg(t)
+}
+
+// getWorkspace returns the Terraform workspace, defaults to `default`
+func (t *Terraform) getWorkspace() string {
+	if t.Spec.Workspace == "" {
+		return defaultWorkspace
+	}
+
+	return t.Spec.Workspace
+}
+
+// getRunnerResources returns the names of the resources the Terraform Runner needs access to
+func (t *Terraform) getRunnerResources() runnerResources {
+	resources := runnerResources{
+		Secrets:    []string{getOutputSecretname(t.Name)},
+		ConfigMaps: []string{getLockFileConfigMapName(t.Name), getRunStateConfigMapName(t.Name)},
+	}
+
+	if t.usesDefaultBackend() {
+		stateSecretName := getStateSecretName(t.Name, t.getWorkspace())
+
+		resources.Secrets = appendUnique(resources.Secrets, stateSecretName)
+		resources.Leases = appendUnique(resources.Leases, getStateLockLeaseName(stateSecretName))
+		resources.ListSecrets = true
+
+		// the Terraform Runner selects the default workspace to delete the workspace of the run, deleting
+		// the workspace deletes its state secret and lock lease
+		if t.shouldDeleteWorkspace() {
+			defaultSecretName := getStateSecretName(t.Name, defaultWorkspace)
+
+			resources.Secrets = appendUnique(resources.Secrets, defaultSecretName)
+			resources.Leases = appendUnique(resources.Leases, getStateLockLeaseName(defaultSecretName))
+			resources.DeletableSecrets = appendUnique(resources.DeletableSecrets, stateSecretName)
+		}
 	}
+
+	// the plan Job saves the plan of the current run and the apply Job reads it, the Role is synced with
+	// the chunk names of every new run
+	if t.Status.RunID != "" {
+		resources.Secrets = appendUnique(resources.Secrets, getPlanChunkSecretNames(t.Name, t.Status.RunID)...)
+	}
+
+	return resources
 }
 
 // runnerRBACName is the RBAC name that will be used in the role and service account creation
 // if they're not found
 const runnerRBACName string = "terraform-runner"
 
+// GetServiceAccountName returns the service account of the Terraform Runner
+func (t *Terraform) GetServiceAccountName() string {
+	if t.Spec.ServiceAccountName == "" {
+		return runnerRBACName
+	}
+
+	return t.Spec.ServiceAccountName
+}
+
+// usesDefaultServiceAccount evaluates if the Terraform Runner runs with the service account managed by the controller
+func (t *Terraform) usesDefaultServiceAccount() bool {
+	return t.GetServiceAccountName() == runnerRBACName
+}
+
+// ensureServiceAccount creates the RBAC of the default service account if not exist, or validates that the
+// custom service account exists. The controller syncs the RBAC of the namespace before a run is created, so
+// the existing RBAC is not reconciled again
+func (t *Terraform) ensureServiceAccount(ctx context.Context, namespace string) error {
+	if t.usesDefaultServiceAccount() {
+		exist, err := isRbacConfigExist(ctx, runnerRBACName, namespace)
+
+		if err != nil || exist {
+			return err
+		}
+
+		_, err = reconcileRbacConfig(ctx, runnerRBACName, namespace, t.getRunnerResources(), false)
+
+		return err
+	}
+
+	exist, err := isServiceAccountExist(ctx, t.Spec.ServiceAccountName, namespace)
+
+	if err != nil {
+		return err
+	}
+
+	if !exist {
+		return fmt.Errorf("service account '%s' was not found in namespace '%s'", t.Spec.ServiceAccountName, namespace)
+	}
+
+	return nil
+}
+
 // CreateTerraformRun creates the Kubernetes objects to start the workflow/run
 //
 // (RBAC (service account & Role), ConfigMap for the terraform module file,
 // Secret to store the outputs if any, will be empty if no outputs are defined,
-// Job to execute the workflow/run)
+// ConfigMap to capture the dependency lock file, Job to execute the workflow/run)
 func (t *Terraform) CreateTerraformRun(ctx context.Context, namespacedName types.NamespacedName) (*batchv1.Job, error) {
 	setBackendCfgIfNotExist(t)
 
-	if err := createRbacConfigIfNotExist(ctx, runnerRBACName, namespacedName.Namespace); err != nil {
+	if err := t.ensureServiceAccount(ctx, namespacedName.Namespace); err != nil {
 		return nil, err
 	}
 
@@ -303,12 +918,41 @@ func (t *Terraform) CreateTerraformRun(ctx context.Context, namespacedName types
 		return nil, err
 	}
 
+	_, err = createConfigMapForLockFile(ctx, namespacedName, t)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = createConfigMapForRunState(ctx, namespacedName, t)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.Status.Stage = ""
+	t.Status.PlanHash = ""
+
+	if t.usesSeparatePlan() {
+		t.Status.Stage = StagePlan
+	}
+
 	job, err := createJobForRun(ctx, t)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if t.Status.Stage == StagePlan {
+		hash, err := t.getInputHash(ctx, job)
+
+		if err != nil {
+			return nil, err
+		}
+
+		t.Status.PlanHash = hash
+	}
+
 	return job, nil
 }
 
@@ -326,24 +970,40 @@ func (t *Terraform) GetOutputSecretName() string {
 	return getOutputSecretname(t.Name)
 }
 
-// CleanupResources cleans up old resources (secrets & configmaps)
+// GetLockFileConfigMapName returns the name of the ConfigMap that holds the captured dependency lock file
+func (t *Terraform) GetLockFileConfigMapName() string {
+	return getLockFileConfigMapName(t.Name)
+}
+
+// CleanupResources deletes the Jobs and ConfigMaps of the previous runs that are beyond the history limit,
+// and the ConfigMaps left behind by runs that failed before their Job was created
 func (t *Terraform) CleanupResources(ctx context.Context) error {
-	previousRunID := t.Status.PreviousRunID
+	jobs, err := listJobsForRun(ctx, t.Name, t.Namespace)
 
-	if previousRunID == "" {
-		return nil
+	if err != nil {
+		return err
 	}
 
-	// delete the older job
-	if err := deleteJobByRun(ctx, t.Name, t.Namespace, previousRunID); err != nil {
-		if !errors.IsNotFound(err) {
-			return err
-		}
+	configMaps, err := listConfigMapsForRun(ctx, t.Name, t.Namespace)
+
+	if err != nil {
+		return err
 	}
 
-	// delete the older configmap that holds the module
-	if err := deleteConfigMapByRun(ctx, t.Name, t.Namespace, previousRunID); err != nil {
-		if !errors.IsNotFound(err) {
+	for _, runID := range t.getExpiredRunIDs(jobs, configMaps) {
+		if err := deleteJobByRun(ctx, t.Name, t.Namespace, runID); err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+		}
+
+		if err := deleteConfigMapByRun(ctx, t.Name, t.Namespace, runID); err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+		}
+
+		if err := deletePlanByRun(ctx, t.Name, t.Namespace, runID); err != nil {
 			return err
 		}
 	}
@@ -353,7 +1013,7 @@ func (t *Terraform) CleanupResources(ctx context.Context) error {
 
 // GetJobByRun returns the Kubernetes job of the workflow/run
 func (t *Terraform) GetJobByRun(ctx context.Context) (*batchv1.Job, error) {
-	job, err := getJobForRun(ctx, t.Name, t.Namespace, t.Status.RunID)
+	job, err := getJobForRun(ctx, t.getJobName(), t.Namespace)
 
 	if err != nil {
 		return nil, err
@@ -123,6 +123,57 @@ var _ = Describe("Terraform", func() {
 			By("run is now in a Completed state")
 			Expect(run2.IsStarted()).To(BeFalse())
 		})
+
+		It("should report the suspended condition", func() {
+			run := &Terraform{}
+
+			Expect(run.IsSuspended()).To(BeFalse())
+
+			run.Status.Conditions = []metav1.Condition{
+				{
+					Type:   ConditionSuspended,
+					Status: metav1.ConditionTrue,
+				},
+			}
+
+			Expect(run.IsSuspended()).To(BeTrue())
+		})
+
+		It("should ignore suspend in the spec hash", func() {
+			run := &Terraform{
+				Spec: TerraformSpec{
+					TerraformVersion: "1.0.2",
+				},
+			}
+
+			hash := run.GetSpecHash()
+			Expect(hash).ToNot(BeEmpty())
+
+			run.Spec.Suspend = true
+			Expect(run.GetSpecHash()).To(Equal(hash))
+
+			run.Spec.TerraformVersion = "1.1.0"
+			Expect(run.GetSpecHash()).ToNot(Equal(hash))
+		})
+
+		It("should detect a requested run through the requested-at annotation", func() {
+			run := &Terraform{}
+
+			Expect(run.IsRequested()).To(BeFalse())
+
+			run.SetAnnotations(map[string]string{
+				RequestedAtAnnotation: "2022-08-01T10:00:00Z",
+			})
+
+			By("the annotation was changed")
+			Expect(run.IsRequested()).To(BeTrue())
+			Expect(run.GetRequestedAt()).To(Equal("2022-08-01T10:00:00Z"))
+
+			run.Status.LastHandledRequestedAt = run.GetRequestedAt()
+
+			By("the request was handled")
+			Expect(run.IsRequested()).To(BeFalse())
+		})
 	})
 
 	Context("Terraform Workflow", func() {
@@ -229,7 +280,7 @@ var _ = Describe("Terraform", func() {
 				},
 			}
 
-			err := createRbacConfigIfNotExist(context.Background(), runnerRBACName, key.Namespace)
+			_, err := reconcileRbacConfig(context.Background(), runnerRBACName, key.Namespace, runnerResources{}, false)
 			Expect(err).ToNot(HaveOccurred())
 
 			job, err := run2.CreateTerraformRun(context.Background(), key)
@@ -238,6 +289,59 @@ var _ = Describe("Terraform", func() {
 		})
 	})
 
+	Context("Terraform Custom Service Account", func() {
+		key := types.NamespacedName{
+			Name:      "custom-sa",
+			Namespace: "default",
+		}
+
+		run := &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Module: Module{
+					Source:  "IbraheemAlSaady/test/module",
+					Version: "0.0.1",
+				},
+				ServiceAccountName: "aws-deployer",
+			},
+			Status: TerraformStatus{
+				RunID: "1234",
+			},
+		}
+
+		It("should fail to create a run if the service account does not exist", func() {
+			job, err := run.CreateTerraformRun(context.Background(), key)
+
+			Expect(err).To(HaveOccurred())
+			Expect(job).To(BeNil())
+		})
+
+		It("should create a run with the custom service account without creating RBAC", func() {
+			_, err := createServiceAccount(context.Background(), run.Spec.ServiceAccountName, key.Namespace)
+			Expect(err).ToNot(HaveOccurred())
+
+			job, err := run.CreateTerraformRun(context.Background(), key)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(job.Spec.Template.Spec.ServiceAccountName).To(Equal(run.Spec.ServiceAccountName))
+
+			found, err := isRoleBindingExist(context.Background(), runnerRBACName, key.Namespace)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("should use the default service account if not provided", func() {
+			run := &Terraform{}
+
+			Expect(run.GetServiceAccountName()).To(Equal(runnerRBACName))
+		})
+	})
+
 	Context("Terraform Error Handling", func() {
 		key := types.NamespacedName{
 			Name:      "barbar",
@@ -405,6 +509,11 @@ var _ = Describe("Terraform", func() {
 				return true, &batchv1.Job{}, errors.New("Error deleting job")
 			})
 
+			run.Status.RunID = "jawd12"
+
+			_, err := run.CreateTerraformRun(context.Background(), key)
+			Expect(err).ToNot(HaveOccurred())
+
 			run.Status.RunID = "1234"
 			run.Status.PreviousRunID = "jawd12"
 
@@ -422,7 +531,12 @@ var _ = Describe("Terraform", func() {
 				return true, &corev1.ConfigMap{}, errors.New("Error deleting config map")
 			})
 
-			err := run.CleanupResources(context.Background())
+			orphan := getConfigMapSpecForModule(run.Name, run.Namespace, "", "", "jawd12", run.GetOwnerReference())
+
+			_, err := kube.ClientSet.CoreV1().ConfigMaps(run.Namespace).Create(context.Background(), orphan, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = run.CleanupResources(context.Background())
 			Expect(err).To(HaveOccurred())
 		})
 	})
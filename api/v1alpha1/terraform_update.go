@@ -0,0 +1,22 @@
+package v1alpha1
+
+// GetUpdatePolicy returns the update policy, defaults to `wait`
+func (t *Terraform) GetUpdatePolicy() UpdatePolicy {
+	if t.Spec.UpdatePolicy == "" {
+		return UpdatePolicyWait
+	}
+
+	return t.Spec.UpdatePolicy
+}
+
+// IsUpdateQueued evaluates if a spec update arrived while the run is in progress and was not yet reported as queued
+func (t *Terraform) IsUpdateQueued() bool {
+	return t.IsUpdated() && t.Status.PendingGeneration != t.Generation
+}
+
+// CanRestart evaluates if the run in progress can be cancelled in favour of a newer spec. Only a plan Job is
+// cancelled, a single Job run may already be applying when it is cancelled, so it always finishes before
+// the update is picked up
+func (t *Terraform) CanRestart() bool {
+	return t.GetUpdatePolicy() == UpdatePolicyCancelAndRestart && t.Status.Stage == StagePlan
+}
@@ -0,0 +1,83 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform Update", func() {
+	key := types.NamespacedName{
+		Name:      "update-run",
+		Namespace: "default",
+	}
+
+	newRun := func() *Terraform {
+		return &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       key.Name,
+				Namespace:  key.Namespace,
+				Generation: 2,
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+			},
+			Status: TerraformStatus{
+				RunID:              "abc123",
+				RunStatus:          RunRunning,
+				ObservedGeneration: 1,
+			},
+		}
+	}
+
+	Context("Queued Update", func() {
+		It("should default the update policy to wait", func() {
+			run := newRun()
+
+			Expect(run.GetUpdatePolicy()).To(Equal(UpdatePolicyWait))
+		})
+
+		It("should report an update only once per generation", func() {
+			run := newRun()
+
+			Expect(run.IsUpdateQueued()).To(BeTrue())
+
+			run.Status.PendingGeneration = run.Generation
+
+			Expect(run.IsUpdateQueued()).To(BeFalse())
+
+			run.Generation = 3
+
+			Expect(run.IsUpdateQueued()).To(BeTrue())
+		})
+	})
+
+	Context("Cancel And Restart", func() {
+		It("should not restart with the wait policy", func() {
+			run := newRun()
+			run.Status.Stage = StagePlan
+
+			Expect(run.CanRestart()).To(BeFalse())
+		})
+
+		It("should not restart a single job run", func() {
+			run := newRun()
+			run.Spec.UpdatePolicy = UpdatePolicyCancelAndRestart
+
+			Expect(run.CanRestart()).To(BeFalse())
+		})
+
+		It("should not restart a run in the apply stage", func() {
+			run := newRun()
+			run.Spec.UpdatePolicy = UpdatePolicyCancelAndRestart
+			run.Status.Stage = StageApply
+
+			Expect(run.CanRestart()).To(BeFalse())
+		})
+	})
+})
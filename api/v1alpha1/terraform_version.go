@@ -0,0 +1,181 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/kuptan/terraform-operator/internal/utils"
+)
+
+// openTofuMinVersion is the first released version of OpenTofu
+var openTofuMinVersion = version.Must(version.NewVersion("1.6.0"))
+
+// blockMinVersions holds the first versions that support the blocks rendered from the spec
+var blockMinVersions = []struct {
+	field   string
+	version *version.Version
+	used    func(t *Terraform) bool
+}{
+	{field: "spec.moved", version: version.Must(version.NewVersion("1.1.0")), used: func(t *Terraform) bool { return len(t.Spec.Moved) > 0 }},
+	{field: "spec.imports", version: version.Must(version.NewVersion("1.5.0")), used: func(t *Terraform) bool { return len(t.Spec.Imports) > 0 }},
+	{field: "spec.removed", version: version.Must(version.NewVersion("1.7.0")), used: func(t *Terraform) bool { return len(t.Spec.Removed) > 0 }},
+}
+
+// checkBlockVersions validates that the resolved version supports the blocks rendered from the spec
+func (t *Terraform) checkBlockVersions(resolved string) error {
+	v, err := version.NewVersion(resolved)
+
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blockMinVersions {
+		if block.used(t) && v.LessThan(block.version) {
+			return fmt.Errorf("%s requires version '%s' or later, the resolved version is '%s'", block.field, block.version, resolved)
+		}
+	}
+
+	return nil
+}
+
+// exactVersionRegex matches a full `major.minor.patch` version, optionally pinned with `=`
+var exactVersionRegex = regexp.MustCompile(`^=?\s*v?[0-9]+\.[0-9]+\.[0-9]+([-+][0-9A-Za-z.+-]+)?$`)
+
+// partialVersionRegex matches a version without its patch or minor segment, optionally pinned with `=`
+var partialVersionRegex = regexp.MustCompile(`^=?\s*v?([0-9]+)(\.([0-9]+))?$`)
+
+// getExactVersion returns the version if the constraint pins a single exact version (e.g. `1.0.2` or `= 1.0.2`)
+func getExactVersion(constraint string) (*version.Version, bool) {
+	constraint = strings.TrimSpace(constraint)
+
+	if !exactVersionRegex.MatchString(constraint) {
+		return nil, false
+	}
+
+	v, err := version.NewVersion(strings.TrimSpace(strings.TrimPrefix(constraint, "=")))
+
+	if err != nil {
+		return nil, false
+	}
+
+	return v, true
+}
+
+// getPartialVersionConstraint returns the range of the releases of a partial version, e.g. `1.0` matches
+// any `1.0.x` release and `1` any `1.x.x` release
+func getPartialVersionConstraint(constraint string) (string, bool) {
+	match := partialVersionRegex.FindStringSubmatch(strings.TrimSpace(constraint))
+
+	if match == nil {
+		return "", false
+	}
+
+	major, _ := strconv.Atoi(match[1])
+
+	if match[3] == "" {
+		return fmt.Sprintf(">= %d.0.0, < %d.0.0", major, major+1), true
+	}
+
+	minor, _ := strconv.Atoi(match[3])
+
+	return fmt.Sprintf(">= %d.%d.0, < %d.%d.0", major, minor, major, minor+1), true
+}
+
+// GetVersionConstraint returns the version constraint of spec.terraformVersion, a partial version is expanded to
+// the range of its releases since Terraform reads a partial version as an exact version (e.g. `1.0` as `1.0.0`)
+func (t *Terraform) GetVersionConstraint() string {
+	if partial, ok := getPartialVersionConstraint(t.Spec.TerraformVersion); ok {
+		return partial
+	}
+
+	return t.Spec.TerraformVersion
+}
+
+// resolveVersion returns the highest version from the available versions that satisfies the constraint, a full
+// version is returned as is and a partial version resolves to its newest release
+func resolveVersion(constraint string, available []string) (string, error) {
+	if partial, ok := getPartialVersionConstraint(constraint); ok {
+		constraint = partial
+	}
+
+	constraints, err := version.NewConstraint(constraint)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid version constraint '%s': %w", constraint, err)
+	}
+
+	if exact, ok := getExactVersion(constraint); ok {
+		return exact.String(), nil
+	}
+
+	candidates := version.Collection{}
+
+	for _, a := range available {
+		v, err := version.NewVersion(strings.TrimSpace(a))
+
+		if err != nil {
+			continue
+		}
+
+		if constraints.Check(v) {
+			candidates = append(candidates, v)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no available version satisfies the constraint '%s'", constraint)
+	}
+
+	sort.Sort(sort.Reverse(candidates))
+
+	return candidates[0].String(), nil
+}
+
+// ResolveTerraformVersion validates the version constraint defined in spec.terraformVersion and returns
+// the concrete version of the engine to run. A full version is returned as is, otherwise the highest version
+// that satisfies the constraint is picked from the available versions of the engine configured in the operator.
+// The version must support the import, moved and removed blocks of the spec
+func (t *Terraform) ResolveTerraformVersion() (string, error) {
+	if t.GetEngine() == EngineOpenTofu {
+		resolved, err := resolveVersion(t.GetVersionConstraint(), utils.Env.OpenTofuVersions)
+
+		if err != nil {
+			return "", err
+		}
+
+		if version.Must(version.NewVersion(resolved)).LessThan(openTofuMinVersion) {
+			return "", fmt.Errorf("opentofu version '%s' is not supported, the minimum version is '%s'", resolved, openTofuMinVersion)
+		}
+
+		if err := t.checkBlockVersions(resolved); err != nil {
+			return "", err
+		}
+
+		return resolved, nil
+	}
+
+	resolved, err := resolveVersion(t.GetVersionConstraint(), utils.Env.TerraformVersions)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := t.checkBlockVersions(resolved); err != nil {
+		return "", err
+	}
+
+	return resolved, nil
+}
+
+// getTerraformVersion returns the resolved Terraform version of the workflow/run
+func (t *Terraform) getTerraformVersion() string {
+	if t.Status.TerraformVersion != "" {
+		return t.Status.TerraformVersion
+	}
+
+	return t.Spec.TerraformVersion
+}
@@ -0,0 +1,177 @@
+package v1alpha1
+
+import (
+	"github.com/kuptan/terraform-operator/internal/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform Version", func() {
+	available := []string{"1.0.2", "1.1.7", "1.2.9", "1.3.0-beta1", "1.3.4", "invalid"}
+
+	Context("Version Constraints", func() {
+		It("should resolve an exact version", func() {
+			v, err := resolveVersion("1.0.2", available)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal("1.0.2"))
+
+			v, err = resolveVersion("= 1.5.0", available)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal("1.5.0"))
+		})
+
+		It("should resolve a partial version to its newest release", func() {
+			v, err := resolveVersion("1.0", []string{"1.0.2", "1.0.11", "1.1.7"})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal("1.0.11"))
+
+			v, err = resolveVersion("= 1", available)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal("1.3.4"))
+
+			_, err = resolveVersion("1.4", available)
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should render a partial version as the range of its releases", func() {
+			run := &Terraform{
+				Spec: TerraformSpec{
+					TerraformVersion: "1.0",
+				},
+			}
+
+			Expect(run.GetVersionConstraint()).To(Equal(">= 1.0.0, < 1.1.0"))
+
+			run.Spec.TerraformVersion = "1.0.2"
+
+			Expect(run.GetVersionConstraint()).To(Equal("1.0.2"))
+		})
+
+		It("should resolve the highest version that satisfies a range", func() {
+			v, err := resolveVersion(">= 1.1.0, < 1.3.0", available)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal("1.2.9"))
+		})
+
+		It("should resolve a pessimistic constraint", func() {
+			v, err := resolveVersion("~> 1.0", available)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal("1.3.4"))
+		})
+
+		It("should fail if the constraint is invalid", func() {
+			_, err := resolveVersion("latest", available)
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fail if no available version satisfies the constraint", func() {
+			_, err := resolveVersion(">= 2.0.0", available)
+
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should resolve the version of a Terraform object", func() {
+			utils.Env.TerraformVersions = available
+
+			run := &Terraform{
+				Spec: TerraformSpec{
+					TerraformVersion: "~> 1.1.0",
+				},
+			}
+
+			v, err := run.ResolveTerraformVersion()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal("1.1.7"))
+			Expect(run.getTerraformVersion()).To(Equal("~> 1.1.0"))
+
+			run.Status.TerraformVersion = v
+			Expect(run.getTerraformVersion()).To(Equal("1.1.7"))
+
+			utils.Env.TerraformVersions = []string{}
+		})
+
+		It("should resolve the version of an OpenTofu engine", func() {
+			utils.Env.OpenTofuVersions = []string{"1.6.2", "1.7.1"}
+
+			run := &Terraform{
+				Spec: TerraformSpec{
+					Engine:           EngineOpenTofu,
+					TerraformVersion: "~> 1.6",
+				},
+			}
+
+			v, err := run.ResolveTerraformVersion()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal("1.7.1"))
+
+			run.Spec.TerraformVersion = "1.0.2"
+
+			_, err = run.ResolveTerraformVersion()
+
+			Expect(err).To(HaveOccurred())
+
+			utils.Env.OpenTofuVersions = []string{}
+		})
+
+		It("should resolve a constraint from the default versions", func() {
+			utils.LoadEnv()
+
+			run := &Terraform{
+				Spec: TerraformSpec{
+					TerraformVersion: "~> 1.2.0",
+				},
+			}
+
+			v, err := run.ResolveTerraformVersion()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal("1.2.9"))
+
+			run.Spec.Engine = EngineOpenTofu
+			run.Spec.TerraformVersion = "~> 1.6.0"
+
+			v, err = run.ResolveTerraformVersion()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal("1.6.3"))
+		})
+
+		It("should refuse a version that does not support the blocks of the spec", func() {
+			run := &Terraform{
+				Spec: TerraformSpec{
+					TerraformVersion: "1.4.6",
+					Imports:          []Import{{Address: "aws_s3_bucket.logs", ID: "logs"}},
+				},
+			}
+
+			_, err := run.ResolveTerraformVersion()
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.imports requires version '1.5.0'"))
+
+			run.Spec.TerraformVersion = "1.5.0"
+
+			v, err := run.ResolveTerraformVersion()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal("1.5.0"))
+
+			run.Spec.Removed = []Removed{{From: "aws_s3_bucket.old"}}
+
+			_, err = run.ResolveTerraformVersion()
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.removed"))
+		})
+	})
+})
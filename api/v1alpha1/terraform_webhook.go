@@ -0,0 +1,109 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/validate-run-terraform-operator-io-v1alpha1-terraform,mutating=false,failurePolicy=fail,sideEffects=None,groups=run.terraform-operator.io,resources=terraforms,verbs=create;update,versions=v1alpha1,name=vterraform.kb.io,admissionReviewVersions=v1
+
+// TerraformValidator rejects Terraform objects that claim a state that is already claimed by another object
+// +kubebuilder:object:generate=false
+type TerraformValidator struct {
+	Client client.Reader
+}
+
+var _ admission.CustomValidator = &TerraformValidator{}
+
+// SetupWebhookWithManager registers the validating webhook of the Terraform objects with the manager
+func (t *Terraform) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(t).
+		WithValidator(&TerraformValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *TerraformValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return v.validateStateClaim(ctx, obj)
+}
+
+// ValidateUpdate implements admission.CustomValidator, the state claim is only validated if the update moves
+// the object to another state. Objects that claimed the same state before the webhook was enabled can still
+// be updated, e.g. to be annotated or to remove their finalizer
+func (v *TerraformValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldRun, ok := oldObj.(*Terraform)
+
+	if !ok {
+		return fmt.Errorf("expected a Terraform object but got %T", oldObj)
+	}
+
+	newRun, ok := newObj.(*Terraform)
+
+	if !ok {
+		return fmt.Errorf("expected a Terraform object but got %T", newObj)
+	}
+
+	if oldRun.GetStateIdentity() == newRun.GetStateIdentity() {
+		return nil
+	}
+
+	return v.validateStateClaim(ctx, newObj)
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (v *TerraformValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+// validateStateClaim returns an error if another Terraform object targets the same state
+func (v *TerraformValidator) validateStateClaim(ctx context.Context, obj runtime.Object) error {
+	run, ok := obj.(*Terraform)
+
+	if !ok {
+		return fmt.Errorf("expected a Terraform object but got %T", obj)
+	}
+
+	if !run.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	list := &TerraformList{}
+
+	if err := v.Client.List(ctx, list); err != nil {
+		return err
+	}
+
+	identity := run.GetStateIdentity()
+
+	for _, other := range list.Items {
+		if other.Namespace == run.Namespace && other.Name == run.Name {
+			continue
+		}
+
+		if !other.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if other.GetStateIdentity() != identity {
+			continue
+		}
+
+		err := field.Duplicate(field.NewPath("spec", "backend"), fmt.Sprintf("the state is already claimed by %s/%s", other.Namespace, other.Name))
+
+		if run.getWorkspace() != defaultWorkspace {
+			err = field.Duplicate(field.NewPath("spec", "workspace"), fmt.Sprintf("the workspace %s of the backend is already claimed by %s/%s", run.getWorkspace(), other.Namespace, other.Name))
+		}
+
+		return apierrors.NewInvalid(GroupVersion.WithKind("Terraform").GroupKind(), run.Name, field.ErrorList{err})
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package v1alpha1
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform Webhook", func() {
+	newRun := func(namespace string, name string, key string) *Terraform {
+		return &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Backend: `backend "s3" {
+  bucket = "terraform-states"
+  key    = "` + key + `"
+}`,
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+			},
+		}
+	}
+
+	newValidator := func(objects ...runtime.Object) *TerraformValidator {
+		scheme := runtime.NewScheme()
+		Expect(AddToScheme(scheme)).To(Succeed())
+
+		return &TerraformValidator{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build(),
+		}
+	}
+
+	Context("State Claim", func() {
+		It("should reject an object claiming the state of another object", func() {
+			validator := newValidator(newRun("team-a", "network", "network.tfstate"))
+
+			err := validator.ValidateCreate(context.Background(), newRun("team-b", "network-copy", "network.tfstate"))
+
+			Expect(err).To(HaveOccurred())
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("team-a/network"))
+		})
+
+		It("should reject an update that moves to the state of another object", func() {
+			existing := newRun("default", "database", "database.tfstate")
+			validator := newValidator(newRun("default", "network", "network.tfstate"), existing)
+
+			updated := existing.DeepCopy()
+			updated.Spec.Backend = newRun("default", "database", "network.tfstate").Spec.Backend
+
+			Expect(validator.ValidateUpdate(context.Background(), existing, updated)).ToNot(Succeed())
+		})
+
+		It("should accept objects with distinct states", func() {
+			existing := newRun("default", "network", "network.tfstate")
+			validator := newValidator(existing)
+
+			Expect(validator.ValidateCreate(context.Background(), newRun("default", "database", "database.tfstate"))).To(Succeed())
+			Expect(validator.ValidateUpdate(context.Background(), existing, existing)).To(Succeed())
+		})
+
+		It("should reject an object claiming the workspace of another object", func() {
+			existing := newRun("default", "network-dev", "network.tfstate")
+			existing.Spec.Workspace = "dev"
+			validator := newValidator(existing)
+
+			run := newRun("default", "network-copy", "network.tfstate")
+			run.Spec.Workspace = "dev"
+
+			err := validator.ValidateCreate(context.Background(), run)
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.workspace"))
+			Expect(err.Error()).To(ContainSubstring("the workspace dev of the backend is already claimed by default/network-dev"))
+
+			run.Spec.Workspace = "prod"
+
+			Expect(validator.ValidateCreate(context.Background(), run)).To(Succeed())
+		})
+
+		It("should accept updates of a duplicate that keep its state", func() {
+			duplicate := newRun("default", "network-duplicate", "network.tfstate")
+			validator := newValidator(newRun("default", "network", "network.tfstate"), duplicate)
+
+			annotated := duplicate.DeepCopy()
+			annotated.SetAnnotations(map[string]string{CancelRequestedAtAnnotation: "2022-08-01T10:00:00Z"})
+
+			Expect(validator.ValidateUpdate(context.Background(), duplicate, annotated)).To(Succeed())
+
+			deleting := newRun("default", "network-deleting", "another.tfstate")
+			now := metav1.Now()
+			deleting.DeletionTimestamp = &now
+
+			moved := deleting.DeepCopy()
+			moved.Spec.Backend = duplicate.Spec.Backend
+
+			Expect(validator.ValidateUpdate(context.Background(), deleting, moved)).To(Succeed())
+		})
+
+		It("should ignore objects that are being deleted", func() {
+			existing := newRun("default", "network", "network.tfstate")
+			now := metav1.Now()
+			existing.DeletionTimestamp = &now
+			existing.Finalizers = []string{TerraformFinalizer}
+
+			validator := newValidator(existing)
+
+			Expect(validator.ValidateCreate(context.Background(), newRun("default", "network-next", "network.tfstate"))).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,72 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultWorkspace is the workspace Terraform starts with, it cannot be deleted
+const defaultWorkspace string = "default"
+
+// shouldCreateWorkspace evaluates if the Terraform Runner creates the workspace, a workspace is created until
+// a run of the object succeeded in it
+func (t *Terraform) shouldCreateWorkspace() bool {
+	workspace := t.getWorkspace()
+
+	return workspace != defaultWorkspace && t.Status.Workspace != workspace
+}
+
+// shouldDeleteWorkspace evaluates if the Terraform Runner deletes the workspace after a successful destroy
+func (t *Terraform) shouldDeleteWorkspace() bool {
+	return t.Spec.Destroy && t.Spec.DeleteWorkspaceOnDestroy && t.getWorkspace() != defaultWorkspace
+}
+
+// getWorkspaceEnvVars returns the environment variables of the workspace lifecycle for the Terraform Runner
+func (t *Terraform) getWorkspaceEnvVars() []corev1.EnvVar {
+	envVars := []corev1.EnvVar{}
+
+	if t.shouldCreateWorkspace() {
+		envVars = append(envVars, getEnvVariable("TERRAFORM_WORKSPACE_CREATE", strconv.FormatBool(true)))
+	}
+
+	if t.shouldDeleteWorkspace() {
+		envVars = append(envVars, getEnvVariable("TERRAFORM_WORKSPACE_DELETE", strconv.FormatBool(true)))
+	}
+
+	return envVars
+}
+
+// SetWorkspace records the active workspace once a run succeeded, the workspace is no longer active once
+// it was deleted after a destroy
+func (t *Terraform) SetWorkspace() {
+	if t.shouldDeleteWorkspace() {
+		t.Status.Workspace = ""
+		return
+	}
+
+	t.Status.Workspace = t.getWorkspace()
+}
+
+// GetWorkspaceClaimant returns the object that already claimed the workspace of the same backend, an
+// object claims a workspace once a run succeeded in it. Objects being deleted are ignored
+func (t *Terraform) GetWorkspaceClaimant(others []Terraform) string {
+	identity := t.GetStateIdentity()
+
+	for _, other := range others {
+		if other.Namespace == t.Namespace && other.Name == t.Name {
+			continue
+		}
+
+		if !other.DeletionTimestamp.IsZero() || other.Status.Workspace == "" {
+			continue
+		}
+
+		if other.Status.Workspace == other.getWorkspace() && other.GetStateIdentity() == identity {
+			return fmt.Sprintf("%s/%s", other.Namespace, other.Name)
+		}
+	}
+
+	return ""
+}
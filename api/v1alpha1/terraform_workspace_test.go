@@ -0,0 +1,121 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Terraform Workspace", func() {
+	newRun := func(name string, workspace string) *Terraform {
+		return &Terraform{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: TerraformSpec{
+				TerraformVersion: "1.0.2",
+				Backend: `backend "s3" {
+  bucket = "terraform-states"
+  key    = "network.tfstate"
+}`,
+				Module: Module{
+					Source: "IbraheemAlSaady/test/module",
+				},
+				Workspace: workspace,
+			},
+		}
+	}
+
+	Context("Workspace Lifecycle", func() {
+		It("should create the workspace until a run succeeded in it", func() {
+			run := newRun("workspace-create", "dev")
+
+			Expect(run.getWorkspaceEnvVars()).To(ContainElement(getEnvVariable("TERRAFORM_WORKSPACE_CREATE", "true")))
+
+			run.SetWorkspace()
+
+			Expect(run.Status.Workspace).To(Equal("dev"))
+			Expect(run.getWorkspaceEnvVars()).To(BeEmpty())
+
+			run.Spec.Workspace = "prod"
+
+			Expect(run.getWorkspaceEnvVars()).To(ContainElement(getEnvVariable("TERRAFORM_WORKSPACE_CREATE", "true")))
+		})
+
+		It("should never create or delete the default workspace", func() {
+			run := newRun("workspace-default", "")
+			run.Spec.Destroy = true
+			run.Spec.DeleteWorkspaceOnDestroy = true
+
+			Expect(run.getWorkspaceEnvVars()).To(BeEmpty())
+
+			run.SetWorkspace()
+
+			Expect(run.Status.Workspace).To(Equal(defaultWorkspace))
+		})
+
+		It("should delete the workspace after a destroy only if requested", func() {
+			run := newRun("workspace-delete", "dev")
+			run.Status.Workspace = "dev"
+			run.Spec.Destroy = true
+
+			Expect(run.getWorkspaceEnvVars()).To(BeEmpty())
+
+			run.Spec.DeleteWorkspaceOnDestroy = true
+
+			Expect(run.getWorkspaceEnvVars()).To(ConsistOf(getEnvVariable("TERRAFORM_WORKSPACE_DELETE", "true")))
+
+			run.SetWorkspace()
+
+			Expect(run.Status.Workspace).To(BeEmpty())
+		})
+
+		It("should pass the workspace lifecycle to the terraform runner", func() {
+			run := newRun("workspace-env", "dev")
+
+			job, err := getJobSpecForRun(run, run.GetOwnerReference())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(getEnvVariable("TERRAFORM_WORKSPACE_CREATE", "true")))
+		})
+	})
+
+	Context("Workspace Claim", func() {
+		claimed := func(name string, workspace string) Terraform {
+			run := newRun(name, workspace)
+			run.Status.Workspace = run.getWorkspace()
+
+			return *run
+		}
+
+		It("should find the object that claimed the workspace", func() {
+			run := newRun("workspace-claim", "dev")
+
+			Expect(run.GetWorkspaceClaimant([]Terraform{claimed("network-dev", "dev")})).To(Equal("default/network-dev"))
+		})
+
+		It("should ignore objects in other workspaces, unclaimed objects and itself", func() {
+			run := newRun("workspace-claim", "dev")
+			unclaimed := newRun("network-pending", "dev")
+
+			others := []Terraform{
+				claimed("network-prod", "prod"),
+				*unclaimed,
+				claimed("workspace-claim", "dev"),
+			}
+
+			Expect(run.GetWorkspaceClaimant(others)).To(BeEmpty())
+		})
+
+		It("should ignore objects that are being deleted", func() {
+			run := newRun("workspace-claim", "dev")
+			deleting := claimed("network-dev", "dev")
+			now := metav1.Now()
+			deleting.DeletionTimestamp = &now
+
+			Expect(run.GetWorkspaceClaimant([]Terraform{deleting})).To(BeEmpty())
+		})
+	})
+})
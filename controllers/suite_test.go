@@ -32,6 +32,7 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -55,10 +56,14 @@ type mockMetricsRecorder struct {
 	metrics.RecorderInterface
 }
 
-func (m *mockMetricsRecorder) RecordTotal(name string, namespace string) {}
-func (m *mockMetricsRecorder) RecordStatus(name string, namespace string, status v1alpha1.TerraformRunStatus) {
+func (m *mockMetricsRecorder) RecordTotal(name string, namespace string, engine v1alpha1.TerraformEngine) {
+}
+func (m *mockMetricsRecorder) RecordStatus(name string, namespace string, engine v1alpha1.TerraformEngine, status v1alpha1.TerraformRunStatus) {
+}
+func (m *mockMetricsRecorder) RecordDuration(name string, namespace string, engine v1alpha1.TerraformEngine, start time.Time) {
+}
+func (m *mockMetricsRecorder) RecordRetry(name string, namespace string, engine v1alpha1.TerraformEngine) {
 }
-func (m *mockMetricsRecorder) RecordDuration(name string, namespace string, start time.Time) {}
 
 var (
 	k8sClient             client.Client
@@ -80,6 +85,7 @@ var _ = BeforeSuite(func() {
 	os.Setenv("DOCKER_REGISTRY", "docker.io")
 	os.Setenv("TERRAFORM_RUNNER_IMAGE", "ibraheemalsaady/terraform-runner")
 	os.Setenv("TERRAFORM_RUNNER_IMAGE_TAG", "0.0.3")
+	os.Setenv("TERRAFORM_RUNNER_CAPABILITIES", "stages,force-unlock")
 	os.Setenv("KNOWN_HOSTS_CONFIGMAP_NAME", "operator-known-hosts")
 
 	By("bootstrapping test environment")
@@ -109,6 +115,7 @@ var _ = BeforeSuite(func() {
 
 	err = (&TerraformReconciler{
 		Client:          k8sClient,
+		APIReader:       k8sClient,
 		Recorder:        k8sManager.GetEventRecorderFor("terraform-controller"),
 		Log:             ctrl.Log.WithName("controllers").WithName("TerraformController"),
 		MetricsRecorder: mockedMetricsRecorder,
@@ -272,6 +279,62 @@ func makeRunJobFail(r *v1alpha1.Terraform) {
 
 }
 
+func updateJobStatus(name string, status batchv1.JobStatus) {
+	jobsClient := kube.ClientSet.BatchV1().Jobs("default")
+
+	job, err := jobsClient.Get(context.Background(), name, metav1.GetOptions{})
+
+	if err != nil {
+		return
+	}
+
+	job.Status = status
+
+	jobsClient.Update(context.Background(), job, metav1.UpdateOptions{})
+}
+
+func makePlanJobSucceed(r *v1alpha1.Terraform) {
+	updateJobStatus(getRunName(r.Name, r.Status.RunID)+"-plan", batchv1.JobStatus{Succeeded: 1})
+}
+
+func makeRunJobTimeOut(r *v1alpha1.Terraform) {
+	updateJobStatus(getRunName(r.Name, r.Status.RunID), batchv1.JobStatus{
+		Failed: 1,
+		Conditions: []batchv1.JobCondition{
+			{
+				Type:   batchv1.JobFailed,
+				Status: corev1.ConditionTrue,
+				Reason: "DeadlineExceeded",
+			},
+		},
+	})
+}
+
+func makeForceUnlockJobSucceed(r *v1alpha1.Terraform) {
+	updateJobStatus(getRunName(r.Name, r.Status.RunID)+"-unlock", batchv1.JobStatus{Succeeded: 1})
+}
+
+func recordLockID(r *v1alpha1.Terraform, lockID string) {
+	configMaps := kube.ClientSet.CoreV1().ConfigMaps("default")
+
+	cm, err := configMaps.Get(context.Background(), r.Name+"-run-state", metav1.GetOptions{})
+
+	if err != nil {
+		return
+	}
+
+	cm.Data = map[string]string{"lockId": lockID}
+
+	configMaps.Update(context.Background(), cm, metav1.UpdateOptions{})
+}
+
+func getTerraform(key types.NamespacedName) *v1alpha1.Terraform {
+	r := &v1alpha1.Terraform{}
+	k8sClient.Get(context.Background(), key, r)
+
+	return r
+}
+
 func isJobDeleted(r *v1alpha1.Terraform) bool {
 	jobsClient := kube.ClientSet.BatchV1().Jobs("default")
 
@@ -39,18 +39,22 @@ import (
 // TerraformReconciler reconciles a Terraform object
 type TerraformReconciler struct {
 	client.Client
+	// APIReader reads from the API server without the cache
+	APIReader         client.Reader
 	Scheme            *runtime.Scheme
 	Recorder          record.EventRecorder
 	MetricsRecorder   metrics.RecorderInterface
 	Log               logr.Logger
 	requeueDependency time.Duration
 	requeueJobWatch   time.Duration
+	historySweep      time.Duration
 }
 
 // TerraformReconcilerOptions holds additional options
 type TerraformReconcilerOptions struct {
 	RequeueDependencyInterval time.Duration
 	RequeueJobWatchInterval   time.Duration
+	HistorySweepInterval      time.Duration
 }
 
 //+kubebuilder:rbac:groups=run.terraform-operator.io,resources=terraforms,verbs=get;list;watch;create;update;patch;delete
@@ -96,7 +100,23 @@ func (r *TerraformReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return r.handleRunDelete(ctx, run)
 	}
 
-	if run.IsSubmitted() || run.IsWaiting() {
+	if run.IsCancelRequested() {
+		return r.handleRunCancel(ctx, run)
+	}
+
+	if run.IsCancelling() {
+		return r.handleRunCancelWatch(ctx, run)
+	}
+
+	if run.Spec.Suspend && !run.IsStarted() {
+		return r.handleRunSuspend(ctx, run)
+	}
+
+	if run.IsSuspended() {
+		return r.handleRunResume(ctx, run)
+	}
+
+	if run.IsSubmitted() || run.IsWaiting() || run.IsWaitingForLock() {
 		result, err := r.handleRunCreate(ctx, run, req.NamespacedName)
 
 		if err != nil {
@@ -104,7 +124,7 @@ func (r *TerraformReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 
 		r.Recorder.Event(run, "Normal", "Created", fmt.Sprintf("Run(%s) submitted", run.Status.RunID))
-		r.MetricsRecorder.RecordTotal(run.Name, run.Namespace)
+		r.MetricsRecorder.RecordTotal(run.Name, run.Namespace, run.GetEngine())
 
 		if result.RequeueAfter > 0 {
 			r.Log.Info(fmt.Sprintf("%s, next run in %s", durationMsg, result.RequeueAfter.String()))
@@ -116,6 +136,18 @@ func (r *TerraformReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	}
 
 	if run.IsStarted() {
+		if run.IsUpdated() {
+			restarting, err := r.handleRunUpdateQueued(ctx, run)
+
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+
+			if restarting {
+				return ctrl.Result{RequeueAfter: r.requeueJobWatch}, nil
+			}
+		}
+
 		result, err := r.handleRunJobWatch(ctx, run)
 
 		if err != nil {
@@ -149,13 +181,49 @@ func (r *TerraformReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
-	return ctrl.Result{}, nil
+	if run.IsRequested() {
+		r.Log.Info("a new terraform run was requested")
+		r.Recorder.Event(run, "Normal", "Requested", fmt.Sprintf("A new run was requested at %s", run.GetRequestedAt()))
+
+		result, err := r.handleRunCreate(ctx, run, req.NamespacedName)
+
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		r.Recorder.Event(run, "Normal", "Created", fmt.Sprintf("Run(%s) submitted", run.Status.RunID))
+		r.MetricsRecorder.RecordTotal(run.Name, run.Namespace, run.GetEngine())
+
+		return result, nil
+	}
+
+	if run.IsRetrying() {
+		if remaining := run.GetRetryRemaining(); remaining > 0 {
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		}
+
+		r.Log.Info("retrying a terraform run", "attempt", run.Status.Attempt+1)
+
+		result, err := r.handleRunCreate(ctx, run, req.NamespacedName)
+
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		r.Recorder.Event(run, "Normal", "Created", fmt.Sprintf("Run(%s) submitted, attempt %d", run.Status.RunID, run.Status.Attempt))
+		r.MetricsRecorder.RecordTotal(run.Name, run.Namespace, run.GetEngine())
+
+		return result, nil
+	}
+
+	return r.handleHistorySweep(ctx, run)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *TerraformReconciler) SetupWithManager(mgr ctrl.Manager, opts TerraformReconcilerOptions) error {
 	r.requeueDependency = opts.RequeueDependencyInterval
 	r.requeueJobWatch = opts.RequeueJobWatchInterval
+	r.historySweep = opts.HistorySweepInterval
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.Terraform{}).
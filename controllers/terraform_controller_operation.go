@@ -4,12 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/kuptan/terraform-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
@@ -17,14 +23,21 @@ import (
 func (r *TerraformReconciler) updateRunStatus(ctx context.Context, run *v1alpha1.Terraform, status v1alpha1.TerraformRunStatus) {
 	run.Status.RunStatus = status
 
-	// set completion time of the run only if status is completed/failed
-	if status == v1alpha1.RunCompleted || status == v1alpha1.RunFailed {
+	// set completion time of the run only if status is completed/failed/timed out/cancelled/blocked
+	if status == v1alpha1.RunCompleted || status == v1alpha1.RunFailed || status == v1alpha1.RunTimedOut || status == v1alpha1.RunCancelled || status == v1alpha1.RunBlocked {
 		run.Status.CompletionTime = time.Now().Format(time.UnixDate)
 	}
 
-	// record the status only if completed/failed/waiting
-	if status == v1alpha1.RunCompleted || status == v1alpha1.RunFailed || status == v1alpha1.RunWaitingForDependency {
-		r.MetricsRecorder.RecordStatus(run.Name, run.Namespace, status)
+	// record the status only if completed/failed/timed out/blocked/waiting
+	if status == v1alpha1.RunCompleted || status == v1alpha1.RunFailed || status == v1alpha1.RunTimedOut || status == v1alpha1.RunBlocked || status == v1alpha1.RunWaitingForDependency || status == v1alpha1.RunWaitingForLock {
+		r.MetricsRecorder.RecordStatus(run.Name, run.Namespace, run.GetEngine(), status)
+	}
+
+	// release the state once the run no longer writes to it
+	if status == v1alpha1.RunCompleted || status == v1alpha1.RunFailed || status == v1alpha1.RunTimedOut || status == v1alpha1.RunCancelled || status == v1alpha1.RunBlocked || status == v1alpha1.RunRetrying {
+		if err := run.ReleaseStateLock(ctx); err != nil {
+			r.Log.Error(err, "failed to release the state lock", "name", run.Name)
+		}
 	}
 
 	if err := r.Status().Update(ctx, run); err != nil {
@@ -35,7 +48,26 @@ func (r *TerraformReconciler) updateRunStatus(ctx context.Context, run *v1alpha1
 func (r *TerraformReconciler) handleRunCreate(ctx context.Context, run *v1alpha1.Terraform, namespacedName types.NamespacedName) (ctrl.Result, error) {
 	dependencies, err := r.checkDependencies(ctx, *run)
 
+	// the run options are read before the requested run is marked as handled
+	optionsErr := run.SetRunOptions()
+
+	run.SetAttempt()
 	run.Status.ObservedGeneration = run.Generation
+	run.Status.PendingGeneration = 0
+	run.Status.LastHandledRequestedAt = run.GetRequestedAt()
+	run.Status.SpecHash = run.GetSpecHash()
+
+	if optionsErr != nil {
+		r.Log.Error(optionsErr, "invalid run options")
+		r.Recorder.Event(run, "Warning", "InvalidRunOptions", optionsErr.Error())
+
+		// the requested run is recorded as failed, it does not run without its options
+		run.SetRunID()
+		run.Status.Message = optionsErr.Error()
+		r.updateRunStatus(ctx, run, v1alpha1.RunFailed)
+
+		return ctrl.Result{}, nil
+	}
 
 	if err != nil {
 		if !run.IsWaiting() {
@@ -48,17 +80,132 @@ func (r *TerraformReconciler) handleRunCreate(ctx context.Context, run *v1alpha1
 		}, nil
 	}
 
+	claimant, err := r.getWorkspaceClaimant(ctx, run)
+
+	if err != nil {
+		r.Log.Error(err, "failed to check the workspace claim", "name", run.Name)
+
+		return ctrl.Result{}, err
+	}
+
+	if claimant != "" {
+		message := fmt.Sprintf("The workspace of the state is already claimed by %s", claimant)
+
+		r.Recorder.Event(run, "Warning", "WorkspaceClaimed", message)
+
+		// the run is recorded as failed, two objects never run against the same workspace
+		run.SetRunID()
+		run.Status.Message = message
+		r.updateRunStatus(ctx, run, v1alpha1.RunFailed)
+
+		return ctrl.Result{}, nil
+	}
+
+	holder, err := run.AcquireStateLock(ctx, r.isStateLockHolderActive(run.GetStateIdentity()))
+
+	if err != nil {
+		r.Log.Error(err, "failed to acquire the state lock", "name", run.Name)
+
+		return ctrl.Result{}, err
+	}
+
+	if holder != "" {
+		if !run.IsWaitingForLock() {
+			message := fmt.Sprintf("The state is in use by %s", holder)
+
+			run.Status.Message = message
+
+			r.Recorder.Event(run, "Normal", "WaitingForLock", message)
+			r.updateRunStatus(ctx, run, v1alpha1.RunWaitingForLock)
+		}
+
+		return ctrl.Result{
+			RequeueAfter: r.requeueDependency,
+		}, nil
+	}
+
 	run.SetRunID()
 
+	version, err := run.ResolveTerraformVersion()
+
+	if err != nil {
+		r.Log.Error(err, "invalid terraform version")
+		r.Recorder.Event(run, "Warning", "InvalidVersion", err.Error())
+
+		run.Status.Message = err.Error()
+		r.updateRunStatus(ctx, run, v1alpha1.RunFailed)
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := run.ValidateEngine(); err != nil {
+		r.Log.Error(err, "invalid engine")
+		r.Recorder.Event(run, "Warning", "InvalidEngine", err.Error())
+
+		run.Status.Message = err.Error()
+		r.updateRunStatus(ctx, run, v1alpha1.RunFailed)
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := run.ValidateCLIOptions(); err != nil {
+		r.Log.Error(err, "invalid cli options")
+		r.Recorder.Event(run, "Warning", "InvalidCLIOptions", err.Error())
+
+		run.Status.Message = err.Error()
+		r.updateRunStatus(ctx, run, v1alpha1.RunFailed)
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := run.ValidateRunnerCapabilities(); err != nil {
+		r.Log.Error(err, "unsupported terraform runner")
+		r.Recorder.Event(run, "Warning", "UnsupportedRunner", err.Error())
+
+		run.Status.Message = err.Error()
+		r.updateRunStatus(ctx, run, v1alpha1.RunFailed)
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := run.ValidateRunnerPodTemplate(); err != nil {
+		r.Log.Error(err, "invalid runner pod template")
+		r.Recorder.Event(run, "Warning", "InvalidRunnerPodTemplate", err.Error())
+
+		run.Status.Message = err.Error()
+		r.updateRunStatus(ctx, run, v1alpha1.RunFailed)
+
+		return ctrl.Result{}, nil
+	}
+
+	run.Status.Engine = run.GetEngine()
+	run.Status.TerraformVersion = version
+	run.Status.Message = ""
+	run.Status.FailureReason = ""
+	run.Status.LockID = ""
+	run.Status.BlockedResources = nil
+	run.Status.PolicyViolations = nil
+	run.Status.FailedHook = ""
+
 	r.Log.Info("cleaning up old resources if exist")
 
 	setVariablesFromDependencies(run, dependencies)
 
+	if err = r.syncRunnerRBAC(ctx, run.Namespace, run, false); err != nil {
+		r.Log.Error(err, "failed to sync the terraform runner rbac")
+
+		run.Status.Message = err.Error()
+		r.updateRunStatus(ctx, run, v1alpha1.RunFailed)
+
+		return ctrl.Result{}, err
+	}
+
 	_, err = run.CreateTerraformRun(ctx, namespacedName)
 
 	if err != nil {
 		r.Log.Error(err, "failed create a terraform run")
 
+		run.Status.Message = err.Error()
 		r.updateRunStatus(ctx, run, v1alpha1.RunFailed)
 
 		return ctrl.Result{}, err
@@ -69,6 +216,7 @@ func (r *TerraformReconciler) handleRunCreate(ctx context.Context, run *v1alpha1
 	}
 
 	run.Status.OutputSecretName = run.GetOutputSecretName()
+	run.Status.LockFileConfigMap = run.GetLockFileConfigMapName()
 	run.Status.StartedTime = time.Now().Format(time.UnixDate)
 
 	r.updateRunStatus(ctx, run, v1alpha1.RunStarted)
@@ -85,7 +233,19 @@ func (r *TerraformReconciler) handleRunUpdate(ctx context.Context, run *v1alpha1
 func (r *TerraformReconciler) handleRunDelete(ctx context.Context, run *v1alpha1.Terraform) (ctrl.Result, error) {
 	r.Log.Info("terraform run is being deleted", "name", run.Name)
 
-	r.MetricsRecorder.RecordStatus(run.Name, run.Namespace, v1alpha1.RunDeleted)
+	if err := r.syncRunnerRBAC(ctx, run.Namespace, run, true); err != nil {
+		r.Log.Error(err, "failed to sync the terraform runner rbac")
+
+		return ctrl.Result{}, err
+	}
+
+	if err := run.ReleaseStateLock(ctx); err != nil {
+		r.Log.Error(err, "failed to release the state lock", "name", run.Name)
+
+		return ctrl.Result{}, err
+	}
+
+	r.MetricsRecorder.RecordStatus(run.Name, run.Namespace, run.GetEngine(), v1alpha1.RunDeleted)
 	controllerutil.RemoveFinalizer(run, v1alpha1.TerraformFinalizer)
 
 	if err := r.Update(ctx, run); err != nil {
@@ -98,19 +258,50 @@ func (r *TerraformReconciler) handleRunDelete(ctx context.Context, run *v1alpha1
 func (r *TerraformReconciler) handleRunJobWatch(ctx context.Context, run *v1alpha1.Terraform) (ctrl.Result, error) {
 	job, err := run.GetJobByRun(ctx)
 
-	r.Log.Info("waiting for terraform job run to complete", "name", job.Name)
-
 	if err != nil {
-		return ctrl.Result{}, err
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		// the job was deleted before its result was seen, e.g. by a short jobTTLSecondsAfterFinished
+		message := fmt.Sprintf("Run(%s) job was deleted before the run finished, its result is unknown", run.Status.RunID)
+
+		r.Recorder.Event(run, "Warning", "JobDeleted", message)
+		r.Log.Error(err, "terraform run job was deleted before it finished", "name", run.Name)
+
+		run.Status.Message = message
+		r.updateRunStatus(ctx, run, v1alpha1.RunFailed)
+
+		return ctrl.Result{}, nil
 	}
 
+	r.Log.Info("waiting for terraform job run to complete", "name", job.Name)
+
 	startTime, err := time.Parse(time.UnixDate, run.Status.StartedTime)
 
 	if err != nil {
 		r.Log.Error(err, "failed to parse workflow start time")
 	}
 
-	defer r.MetricsRecorder.RecordDuration(run.Name, run.Namespace, startTime)
+	defer r.MetricsRecorder.RecordDuration(run.Name, run.Namespace, run.GetEngine(), startTime)
+
+	// job exceeded the run timeout, its pods are being terminated
+	if isJobDeadlineExceeded(job) {
+		message := fmt.Sprintf("Run(%s) exceeded its timeout", run.Status.RunID)
+
+		if job.Spec.ActiveDeadlineSeconds != nil {
+			message = fmt.Sprintf("Run(%s) exceeded the timeout of %s", run.Status.RunID, time.Duration(*job.Spec.ActiveDeadlineSeconds)*time.Second)
+		}
+
+		r.Recorder.Event(run, "Warning", "TimedOut", message)
+		r.Log.Error(errors.New("job timed out"), "terraform run job exceeded its active deadline", "name", job.Name)
+
+		r.setPlanSummary(ctx, run)
+
+		run.Status.Message = message
+
+		return r.handleRunFailure(ctx, run, v1alpha1.RunTimedOut, v1alpha1.FailureTimedOut)
+	}
 
 	// job hasn't started
 	if job.Status.Active == 0 && job.Status.Succeeded == 0 && job.Status.Failed == 0 {
@@ -132,6 +323,12 @@ func (r *TerraformReconciler) handleRunJobWatch(ctx context.Context, run *v1alph
 	if job.Status.Succeeded > 0 {
 		r.Log.Info("terraform run job completed successfully")
 
+		r.setPlanSummary(ctx, run)
+
+		if run.Status.Stage == v1alpha1.StagePlan {
+			return r.handleRunPlanned(ctx, run)
+		}
+
 		if run.Spec.DeleteCompletedJobs {
 			r.Log.Info("deleting completed job")
 
@@ -148,8 +345,14 @@ func (r *TerraformReconciler) handleRunJobWatch(ctx context.Context, run *v1alph
 			r.Recorder.Event(run, "Normal", "Destroyed", fmt.Sprintf("Run(%s) completed with terraform destroy", run.Status.RunID))
 		}
 
+		run.SetWorkspace()
 		r.updateRunStatus(ctx, run, v1alpha1.RunCompleted)
 
+		// pick up the spec update that was queued while the run was in progress
+		if run.IsUpdated() {
+			return ctrl.Result{Requeue: true}, nil
+		}
+
 		return ctrl.Result{}, nil
 	}
 
@@ -157,11 +360,469 @@ func (r *TerraformReconciler) handleRunJobWatch(ctx context.Context, run *v1alph
 	r.Recorder.Event(run, "Warning", "Failed", fmt.Sprintf("Run(%s) failed", run.Status.RunID))
 	r.Log.Error(errors.New("job failed"), "terraform run job failed to complete", "name", job.Name)
 
-	r.updateRunStatus(ctx, run, v1alpha1.RunFailed)
+	hook, hookMessage, err := run.GetFailedHook(ctx)
+
+	if err != nil {
+		r.Log.Error(err, "failed to get the failed hook of the terraform run", "name", job.Name)
+	}
+
+	if hook != "" {
+		message := fmt.Sprintf("Run(%s) hook %s failed", run.Status.RunID, hook)
+
+		if hookMessage != "" {
+			message = fmt.Sprintf("%s: %s", message, hookMessage)
+		}
+
+		r.Recorder.Event(run, "Warning", "HookFailed", fmt.Sprintf("Run(%s) hook %s failed", run.Status.RunID, hook))
+		r.setPlanSummary(ctx, run)
+
+		run.Status.FailedHook = hook
+		run.Status.Message = message
+
+		return r.handleRunFailure(ctx, run, v1alpha1.RunFailed, v1alpha1.FailureHook)
+	}
+
+	message, err := run.GetFailureMessage(ctx)
+
+	if err != nil {
+		r.Log.Error(err, "failed to get the failure message of the terraform run", "name", job.Name)
+	}
+
+	if message == "" {
+		message = fmt.Sprintf("Run(%s) failed", run.Status.RunID)
+	}
+
+	r.setPlanSummary(ctx, run)
+
+	run.Status.Message = message
+
+	return r.handleRunFailure(ctx, run, v1alpha1.RunFailed, v1alpha1.GetFailureReason(message))
+}
+
+// handleRunPlanned starts the apply Job of a run with a separate plan once the plan Job succeeded. The run is
+// blocked if the plan exceeds the protection or is denied by a policy, and the apply is refused if the spec,
+// the module or the inputs changed since planning
+func (r *TerraformReconciler) handleRunPlanned(ctx context.Context, run *v1alpha1.Terraform) (ctrl.Result, error) {
+	message, resources, err := run.CheckProtection(ctx)
+
+	if err != nil {
+		r.Log.Error(err, "failed to check the plan against the protection", "name", run.Name)
+
+		return ctrl.Result{}, err
+	}
+
+	if message != "" {
+		run.Status.BlockedResources = resources
+
+		return r.handleRunBlocked(ctx, run, message)
+	}
+
+	violations, err := run.CheckPolicies(ctx)
+
+	if err != nil {
+		r.Log.Error(err, "failed to evaluate the plan against the policies", "name", run.Name)
+		r.Recorder.Event(run, "Warning", "PolicyCheckFailed", fmt.Sprintf("Run(%s) policies could not be evaluated, %s", run.Status.RunID, err.Error()))
+
+		return r.handleRunBlocked(ctx, run, fmt.Sprintf("the policies could not be evaluated, %s", err.Error()))
+	}
+
+	if len(violations) > 0 {
+		run.Status.PolicyViolations = violations
+
+		return r.handleRunBlocked(ctx, run, fmt.Sprintf("the plan was denied by %d policy violations", len(violations)))
+	}
+
+	if _, err := run.CreateApplyJob(ctx); err != nil {
+		if !errors.Is(err, v1alpha1.ErrPlanOutdated) {
+			r.Log.Error(err, "failed to create the apply job", "name", run.Name)
+
+			return ctrl.Result{}, err
+		}
+
+		message := fmt.Sprintf("Run(%s) was not applied, %s", run.Status.RunID, err.Error())
+
+		r.Recorder.Event(run, "Warning", "PlanOutdated", message)
+
+		run.Status.Message = message
+
+		return r.handleRunFailure(ctx, run, v1alpha1.RunFailed, v1alpha1.FailureError)
+	}
+
+	r.Recorder.Event(run, "Normal", "Applying", fmt.Sprintf("Run(%s) applying the saved plan", run.Status.RunID))
+	r.updateRunStatus(ctx, run, v1alpha1.RunStarted)
+
+	return ctrl.Result{RequeueAfter: r.requeueJobWatch}, nil
+}
+
+// handleRunBlocked stops a run whose plan was not allowed to be applied, a new run is only started once the
+// spec is updated or a new run is requested
+func (r *TerraformReconciler) handleRunBlocked(ctx context.Context, run *v1alpha1.Terraform, reason string) (ctrl.Result, error) {
+	message := fmt.Sprintf("Run(%s) was blocked, %s", run.Status.RunID, reason)
+
+	r.Recorder.Event(run, "Warning", "Blocked", message)
+
+	run.Status.Message = message
+
+	r.updateRunStatus(ctx, run, v1alpha1.RunBlocked)
+
+	// pick up the spec update that was queued while the run was in progress
+	if run.IsUpdated() {
+		return ctrl.Result{Requeue: true}, nil
+	}
 
 	return ctrl.Result{}, nil
 }
 
+// setPlanSummary summarises the plan saved by the Terraform Runner into the status, a run that failed
+// before the plan was saved keeps the summary of the previous plan
+func (r *TerraformReconciler) setPlanSummary(ctx context.Context, run *v1alpha1.Terraform) {
+	if err := run.SetPlanSummary(ctx); err != nil {
+		r.Log.Error(err, "failed to summarise the plan of the terraform run", "name", run.Name)
+
+		return
+	}
+
+	if plan := run.Status.LastPlan; plan != nil && plan.RunID == run.Status.RunID {
+		message := fmt.Sprintf("Run(%s) plan: %d to add, %d to change, %d to destroy", plan.RunID, plan.Add, plan.Change, plan.Destroy)
+
+		if plan.Cost != nil {
+			message = fmt.Sprintf("%s, %s %s monthly cost delta", message, plan.Cost.MonthlyCostDelta, plan.Cost.Currency)
+		}
+
+		r.Recorder.Event(run, "Normal", "Planned", message)
+	}
+}
+
+// handleRunFailure sets the failure status of the run, or schedules a new run after a backoff
+// if the failure should be retried based on the retry policy
+func (r *TerraformReconciler) handleRunFailure(ctx context.Context, run *v1alpha1.Terraform, status v1alpha1.TerraformRunStatus, reason v1alpha1.FailureReason) (ctrl.Result, error) {
+	run.Status.FailureReason = reason
+
+	// the spec update that was queued while the run was in progress takes precedence over a retry
+	if run.IsUpdated() {
+		r.updateRunStatus(ctx, run, status)
+
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if !run.ShouldRetry(reason) {
+		r.updateRunStatus(ctx, run, status)
+
+		return ctrl.Result{}, nil
+	}
+
+	delay := run.GetRetryDelay()
+
+	run.Status.NextRetryTime = time.Now().Add(delay).Format(time.UnixDate)
+
+	r.Recorder.Event(run, "Warning", "Retrying", fmt.Sprintf("Run(%s) attempt %d failed with reason %s, retrying in %s", run.Status.RunID, run.Status.Attempt, reason, delay))
+	r.MetricsRecorder.RecordRetry(run.Name, run.Namespace, run.GetEngine())
+
+	r.updateRunStatus(ctx, run, v1alpha1.RunRetrying)
+
+	return ctrl.Result{RequeueAfter: delay}, nil
+}
+
+// handleRunUpdateQueued reports a spec update that arrived while the run is in progress as queued, and cancels
+// the run in favour of the newer spec if the update policy allows it and the run is in the plan stage
+func (r *TerraformReconciler) handleRunUpdateQueued(ctx context.Context, run *v1alpha1.Terraform) (bool, error) {
+	if run.IsUpdateQueued() {
+		run.Status.PendingGeneration = run.Generation
+
+		r.Recorder.Event(run, "Normal", "Queued", fmt.Sprintf("Generation %d is queued until Run(%s) finishes", run.Generation, run.Status.RunID))
+
+		if err := r.Status().Update(ctx, run); err != nil {
+			return false, err
+		}
+	}
+
+	if !run.CanRestart() {
+		return false, nil
+	}
+
+	if err := run.CancelRun(ctx); err != nil {
+		r.Log.Error(err, "failed to cancel the terraform run", "name", run.Name)
+
+		return false, err
+	}
+
+	r.Recorder.Event(run, "Normal", "Restarting", fmt.Sprintf("Run(%s) is being cancelled in favour of generation %d", run.Status.RunID, run.Generation))
+	r.updateRunStatus(ctx, run, v1alpha1.RunCancelling)
+
+	return true, nil
+}
+
+// handleRunSuspend reports the reconciliation as suspended, no new runs are created while suspended
+func (r *TerraformReconciler) handleRunSuspend(ctx context.Context, run *v1alpha1.Terraform) (ctrl.Result, error) {
+	if run.IsSuspended() {
+		return ctrl.Result{}, nil
+	}
+
+	meta.SetStatusCondition(&run.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionSuspended,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Suspended",
+		Message:            "Reconciliation is suspended, no new runs are created",
+		ObservedGeneration: run.Generation,
+	})
+
+	r.Recorder.Event(run, "Normal", "Suspended", "Reconciliation is suspended")
+
+	if err := r.Status().Update(ctx, run); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// handleRunResume reports the reconciliation as resumed. Resuming does not start a new run
+// unless the spec was changed while suspended
+func (r *TerraformReconciler) handleRunResume(ctx context.Context, run *v1alpha1.Terraform) (ctrl.Result, error) {
+	meta.SetStatusCondition(&run.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionSuspended,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Resumed",
+		Message:            "Reconciliation is resumed",
+		ObservedGeneration: run.Generation,
+	})
+
+	if run.Status.SpecHash != "" && run.Status.SpecHash == run.GetSpecHash() {
+		run.Status.ObservedGeneration = run.Generation
+	}
+
+	r.Recorder.Event(run, "Normal", "Resumed", "Reconciliation is resumed")
+
+	if err := r.Status().Update(ctx, run); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// handleRunCancel cancels the run in progress, a run waiting for a dependency or a retry is cancelled right away
+func (r *TerraformReconciler) handleRunCancel(ctx context.Context, run *v1alpha1.Terraform) (ctrl.Result, error) {
+	run.Status.LastHandledCancelAt = run.GetCancelRequestedAt()
+
+	if run.IsStarted() {
+		if err := run.CancelRun(ctx); err != nil {
+			r.Log.Error(err, "failed to cancel the terraform run", "name", run.Name)
+
+			return ctrl.Result{}, err
+		}
+
+		r.Recorder.Event(run, "Normal", "Cancelling", fmt.Sprintf("Run(%s) is being cancelled", run.Status.RunID))
+		r.updateRunStatus(ctx, run, v1alpha1.RunCancelling)
+
+		return ctrl.Result{RequeueAfter: r.requeueJobWatch}, nil
+	}
+
+	if run.IsWaiting() || run.IsWaitingForLock() || run.IsRetrying() {
+		message := fmt.Sprintf("Run(%s) was cancelled", run.Status.RunID)
+
+		run.Status.NextRetryTime = ""
+		run.Status.Message = message
+
+		r.Recorder.Event(run, "Normal", "Cancelled", message)
+		r.updateRunStatus(ctx, run, v1alpha1.RunCancelled)
+
+		return ctrl.Result{}, nil
+	}
+
+	r.Recorder.Event(run, "Normal", "CancelIgnored", "There is no run in progress to cancel")
+
+	if err := r.Status().Update(ctx, run); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// handleRunCancelWatch waits for the Terraform Runner to exit, then marks the run as cancelled. If the run left
+// its lock on the state and the cancel policy allows it, the run stays cancelling until the force-unlock Job
+// finished and its result is reported
+func (r *TerraformReconciler) handleRunCancelWatch(ctx context.Context, run *v1alpha1.Terraform) (ctrl.Result, error) {
+	terminated, err := run.IsRunnerTerminated(ctx)
+
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !terminated {
+		r.Log.Info("waiting for the terraform runner to exit", "name", run.Name)
+
+		return ctrl.Result{RequeueAfter: r.requeueJobWatch}, nil
+	}
+
+	unlockJob, err := run.GetForceUnlockJob(ctx)
+
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if unlockJob != nil {
+		return r.handleForceUnlockWatch(ctx, run, unlockJob)
+	}
+
+	lockID, err := run.GetStateLockID(ctx)
+
+	if err != nil {
+		r.Log.Error(err, "failed to get the state lock of the cancelled run", "name", run.Name)
+	}
+
+	run.Status.LockID = lockID
+
+	if lockID != "" {
+		if run.Spec.CancelPolicy != nil && run.Spec.CancelPolicy.ForceUnlock {
+			if _, err := run.CreateForceUnlockJob(ctx); err != nil {
+				r.Log.Error(err, "failed to create the force-unlock job", "name", run.Name)
+				r.Recorder.Event(run, "Warning", "ForceUnlockFailed", fmt.Sprintf("Failed to force-unlock the state lock %s: %s", lockID, err.Error()))
+			} else {
+				r.Recorder.Event(run, "Normal", "ForceUnlock", fmt.Sprintf("Running terraform force-unlock for the state lock %s", lockID))
+				r.updateRunStatus(ctx, run, v1alpha1.RunCancelling)
+
+				return ctrl.Result{RequeueAfter: r.requeueJobWatch}, nil
+			}
+		} else {
+			r.Recorder.Event(run, "Warning", "StateLocked", fmt.Sprintf("The state is still locked by %s", lockID))
+		}
+	}
+
+	message := fmt.Sprintf("Run(%s) was cancelled", run.Status.RunID)
+
+	run.Status.Message = message
+
+	r.Recorder.Event(run, "Normal", "Cancelled", message)
+	r.updateRunStatus(ctx, run, v1alpha1.RunCancelled)
+
+	return ctrl.Result{}, nil
+}
+
+// handleForceUnlockWatch waits for the force-unlock Job of a cancelled run to finish and reports its result,
+// the lock ID is cleared once the state was unlocked
+func (r *TerraformReconciler) handleForceUnlockWatch(ctx context.Context, run *v1alpha1.Terraform, job *batchv1.Job) (ctrl.Result, error) {
+	if job.Status.Succeeded == 0 && job.Status.Failed == 0 {
+		r.Log.Info("waiting for the force-unlock job to finish", "name", job.Name)
+
+		return ctrl.Result{RequeueAfter: r.requeueJobWatch}, nil
+	}
+
+	message := fmt.Sprintf("Run(%s) was cancelled", run.Status.RunID)
+
+	if job.Status.Succeeded > 0 {
+		r.Recorder.Event(run, "Normal", "ForceUnlocked", fmt.Sprintf("The state lock %s was force-unlocked", run.Status.LockID))
+
+		run.Status.LockID = ""
+	} else {
+		r.Recorder.Event(run, "Warning", "ForceUnlockFailed", fmt.Sprintf("The force-unlock job %s failed, the state is still locked by %s", job.Name, run.Status.LockID))
+
+		message = fmt.Sprintf("%s, the state lock %s could not be force-unlocked", message, run.Status.LockID)
+	}
+
+	run.Status.Message = message
+
+	r.Recorder.Event(run, "Normal", "Cancelled", message)
+	r.updateRunStatus(ctx, run, v1alpha1.RunCancelled)
+
+	return ctrl.Result{}, nil
+}
+
+// isJobDeadlineExceeded evaluates if the job failed due to exceeding its active deadline
+func isJobDeadlineExceeded(job *batchv1.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == v1.ConditionTrue && c.Reason == "DeadlineExceeded" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleHistorySweep deletes the Jobs and ConfigMaps of the previous runs beyond the history limit of a
+// finished run, and sweeps again periodically to collect the objects of runs that were interrupted
+func (r *TerraformReconciler) handleHistorySweep(ctx context.Context, run *v1alpha1.Terraform) (ctrl.Result, error) {
+	if err := run.CleanupResources(ctx); err != nil {
+		r.Log.Error(err, "failed to cleanup resources")
+	}
+
+	if r.historySweep > 0 {
+		return ctrl.Result{RequeueAfter: r.historySweep}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// isStateLockHolderActive returns a function that evaluates if the holder of a state lease is still running
+// against the given state. The holder is read from the API server since the cache can be stale, and a holder
+// with a running Job is active whatever its status says. A holder that was deleted, or finished its run
+// or moved to another state and has no running Job is not active
+func (r *TerraformReconciler) isStateLockHolderActive(identity string) v1alpha1.StateLockHolderFunc {
+	return func(ctx context.Context, namespace string, name string) (bool, error) {
+		holder := &v1alpha1.Terraform{}
+
+		if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, holder); err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		if (holder.IsStarted() || holder.IsCancelling()) && holder.GetStateIdentity() == identity {
+			return true, nil
+		}
+
+		return holder.HasActiveJob(ctx)
+	}
+}
+
+// getWorkspaceClaimant returns the Terraform object that already claimed the workspace of the run in the
+// same backend
+func (r *TerraformReconciler) getWorkspaceClaimant(ctx context.Context, run *v1alpha1.Terraform) (string, error) {
+	list := &v1alpha1.TerraformList{}
+
+	if err := r.List(ctx, list); err != nil {
+		return "", err
+	}
+
+	return run.GetWorkspaceClaimant(list.Items), nil
+}
+
+// syncRunnerRBAC scopes the runner RBAC of a namespace to the Terraform objects in it and repairs drifted
+// RBAC objects, the current run replaces its stored version or is left out if it is being deleted
+func (r *TerraformReconciler) syncRunnerRBAC(ctx context.Context, namespace string, current *v1alpha1.Terraform, deleted bool) error {
+	list := &v1alpha1.TerraformList{}
+
+	if err := r.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+
+	runs := []v1alpha1.Terraform{}
+
+	for _, item := range list.Items {
+		if item.Name == current.Name || !item.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		runs = append(runs, item)
+	}
+
+	if !deleted {
+		runs = append(runs, *current)
+	}
+
+	repaired, err := v1alpha1.SyncRunnerRBAC(ctx, namespace, runs)
+
+	if err != nil {
+		return err
+	}
+
+	if len(repaired) > 0 {
+		r.Recorder.Event(current, "Warning", "RBACRepaired", fmt.Sprintf("Repaired drifted runner RBAC: %s", strings.Join(repaired, ", ")))
+	}
+
+	return nil
+}
+
 func (r *TerraformReconciler) checkDependencies(ctx context.Context, run v1alpha1.Terraform) ([]v1alpha1.Terraform, error) {
 	dependencies := []v1alpha1.Terraform{}
 
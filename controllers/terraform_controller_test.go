@@ -8,6 +8,7 @@ import (
 	"github.com/kuptan/terraform-operator/internal/kube"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
@@ -449,4 +450,331 @@ var _ = Describe("Terraform Controller", func() {
 			}, timeout, interval).Should(Equal(v1alpha1.RunRunning))
 		})
 	})
+
+	Context("Terraform Run Lifecycle", func() {
+		newRun := func(name string, stateKey string) *v1alpha1.Terraform {
+			return &v1alpha1.Terraform{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "default",
+				},
+				Spec: v1alpha1.TerraformSpec{
+					TerraformVersion: "1.0.2",
+					Backend: `backend "s3" {
+  bucket = "terraform-states"
+  key    = "` + stateKey + `"
+}`,
+					Module: v1alpha1.Module{
+						Source:  "IbraheemAlSaady/test/module",
+						Version: "0.0.1",
+					},
+					Variables: []v1alpha1.Variable{
+						{
+							Key:   "length",
+							Value: "16",
+						},
+					},
+				},
+			}
+		}
+
+		requestCancel := func(key types.NamespacedName) {
+			Eventually(func() error {
+				r := getTerraform(key)
+				r.Annotations = map[string]string{v1alpha1.CancelRequestedAtAnnotation: time.Now().Format(time.RFC3339Nano)}
+
+				return k8sClient.Update(context.Background(), r)
+			}, timeout, interval).Should(Succeed())
+		}
+
+		It("should cancel a run in progress", func() {
+			key := types.NamespacedName{Name: "run-cancel", Namespace: "default"}
+
+			Expect(k8sClient.Create(context.Background(), newRun(key.Name, "cancel.tfstate"))).Should(Succeed())
+
+			By("expect status to be started")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				return getTerraform(key).Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunStarted))
+
+			requestCancel(key)
+
+			By("expect status to be cancelled")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				return getTerraform(key).Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunCancelled))
+
+			r := getTerraform(key)
+
+			job, err := kube.ClientSet.BatchV1().Jobs(key.Namespace).Get(context.Background(), getRunName(r.Name, r.Status.RunID), metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(job.Spec.Suspend).ToNot(BeNil())
+			Expect(*job.Spec.Suspend).To(BeTrue())
+			Expect(r.Status.LockID).To(BeEmpty())
+		})
+
+		It("should force-unlock the state lock left by a cancelled run", func() {
+			key := types.NamespacedName{Name: "run-force-unlock", Namespace: "default"}
+
+			run := newRun(key.Name, "force-unlock.tfstate")
+			run.Spec.CancelPolicy = &v1alpha1.CancelPolicy{ForceUnlock: true}
+
+			Expect(k8sClient.Create(context.Background(), run)).Should(Succeed())
+
+			By("expect status to be started")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				return getTerraform(key).Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunStarted))
+
+			recordLockID(getTerraform(key), "a1b2c3")
+			requestCancel(key)
+
+			By("expect the lock of the run to be recorded while it is force-unlocked")
+			Eventually(func() string {
+				r := getTerraform(key)
+
+				if r.Status.RunStatus != v1alpha1.RunCancelling {
+					return ""
+				}
+
+				return r.Status.LockID
+			}, timeout, interval).Should(Equal("a1b2c3"))
+
+			By("expect status to be cancelled once the force-unlock job succeeded")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				r := getTerraform(key)
+
+				makeForceUnlockJobSucceed(r)
+
+				return r.Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunCancelled))
+
+			Expect(getTerraform(key).Status.LockID).To(BeEmpty())
+		})
+
+		It("should not start a run while suspended", func() {
+			key := types.NamespacedName{Name: "run-suspend", Namespace: "default"}
+
+			run := newRun(key.Name, "suspend.tfstate")
+			run.Spec.Suspend = true
+
+			Expect(k8sClient.Create(context.Background(), run)).Should(Succeed())
+
+			By("expect the reconciliation to be suspended")
+			Eventually(func() bool {
+				return getTerraform(key).IsSuspended()
+			}, timeout, interval).Should(BeTrue())
+
+			Consistently(func() string {
+				return getTerraform(key).Status.RunID
+			}, time.Second*3, interval).Should(BeEmpty())
+
+			Eventually(func() error {
+				r := getTerraform(key)
+				r.Spec.Suspend = false
+
+				return k8sClient.Update(context.Background(), r)
+			}, timeout, interval).Should(Succeed())
+
+			By("expect a run to start once resumed")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				return getTerraform(key).Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunStarted))
+
+			Expect(getTerraform(key).IsSuspended()).To(BeFalse())
+		})
+
+		It("should wait for the state lock held by another run", func() {
+			holderKey := types.NamespacedName{Name: "run-lock-holder", Namespace: "default"}
+			waiterKey := types.NamespacedName{Name: "run-lock-waiter", Namespace: "default"}
+
+			Expect(k8sClient.Create(context.Background(), newRun(holderKey.Name, "shared.tfstate"))).Should(Succeed())
+
+			By("expect the holder to be started")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				return getTerraform(holderKey).Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunStarted))
+
+			Expect(k8sClient.Create(context.Background(), newRun(waiterKey.Name, "shared.tfstate"))).Should(Succeed())
+
+			By("expect the waiter to wait for the lock")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				return getTerraform(waiterKey).Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunWaitingForLock))
+
+			requestCancel(holderKey)
+
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				return getTerraform(holderKey).Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunCancelled))
+
+			By("expect the waiter to start once the lock is released")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				return getTerraform(waiterKey).Status.RunStatus
+			}, timeout*2, interval).Should(Equal(v1alpha1.RunStarted))
+		})
+
+		It("should queue an update until the run in progress finished", func() {
+			key := types.NamespacedName{Name: "run-queued", Namespace: "default"}
+
+			Expect(k8sClient.Create(context.Background(), newRun(key.Name, "queued.tfstate"))).Should(Succeed())
+
+			By("expect status to be running")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				r := getTerraform(key)
+
+				makeRunJobRunning(r)
+
+				return r.Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunRunning))
+
+			queued := getTerraform(key)
+			runID := queued.Status.RunID
+
+			Eventually(func() error {
+				r := getTerraform(key)
+				r.Spec.Variables[0].Value = "32"
+
+				return k8sClient.Update(context.Background(), r)
+			}, timeout, interval).Should(Succeed())
+
+			By("expect the update to be queued")
+			Eventually(func() bool {
+				r := getTerraform(key)
+
+				return r.Status.PendingGeneration == r.Generation
+			}, timeout, interval).Should(BeTrue())
+
+			r := getTerraform(key)
+			Expect(r.Status.RunID).To(Equal(runID))
+			Expect(r.Status.RunStatus).To(Equal(v1alpha1.RunRunning))
+
+			updateJobStatus(getRunName(key.Name, runID), batchv1.JobStatus{Succeeded: 1})
+
+			By("expect a new run with the update once the run finished")
+			Eventually(func() string {
+				return getTerraform(key).Status.RunID
+			}, timeout, interval).ShouldNot(Equal(runID))
+
+			r = getTerraform(key)
+			Expect(r.Status.ObservedGeneration).To(Equal(r.Generation))
+		})
+
+		It("should apply the saved plan once the plan job succeeded", func() {
+			key := types.NamespacedName{Name: "run-separate-plan", Namespace: "default"}
+
+			run := newRun(key.Name, "separate-plan.tfstate")
+			run.Spec.SeparatePlan = true
+
+			Expect(k8sClient.Create(context.Background(), run)).Should(Succeed())
+
+			By("expect the run to start with the plan stage")
+			Eventually(func() v1alpha1.RunStage {
+				return getTerraform(key).Status.Stage
+			}, timeout, interval).Should(Equal(v1alpha1.StagePlan))
+
+			By("expect the apply stage once the plan job succeeded")
+			Eventually(func() v1alpha1.RunStage {
+				r := getTerraform(key)
+
+				makePlanJobSucceed(r)
+
+				return r.Status.Stage
+			}, timeout, interval).Should(Equal(v1alpha1.StageApply))
+
+			r := getTerraform(key)
+			Expect(r.Status.RunStatus).To(Equal(v1alpha1.RunStarted))
+
+			_, err := kube.ClientSet.BatchV1().Jobs(key.Namespace).Get(context.Background(), getRunName(r.Name, r.Status.RunID), metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			By("expect status to be completed once the apply job succeeded")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				r := getTerraform(key)
+
+				makeRunJobSucceed(r)
+
+				return r.Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunCompleted))
+		})
+
+		It("should cancel and restart a run in the plan stage", func() {
+			key := types.NamespacedName{Name: "run-restart", Namespace: "default"}
+
+			run := newRun(key.Name, "restart.tfstate")
+			run.Spec.SeparatePlan = true
+			run.Spec.UpdatePolicy = v1alpha1.UpdatePolicyCancelAndRestart
+
+			Expect(k8sClient.Create(context.Background(), run)).Should(Succeed())
+
+			By("expect the run to start with the plan stage")
+			Eventually(func() v1alpha1.RunStage {
+				return getTerraform(key).Status.Stage
+			}, timeout, interval).Should(Equal(v1alpha1.StagePlan))
+
+			runID := getTerraform(key).Status.RunID
+
+			Eventually(func() error {
+				r := getTerraform(key)
+				r.Spec.Variables[0].Value = "32"
+
+				return k8sClient.Update(context.Background(), r)
+			}, timeout, interval).Should(Succeed())
+
+			By("expect a new run with the update")
+			Eventually(func() string {
+				return getTerraform(key).Status.RunID
+			}, timeout, interval).ShouldNot(Equal(runID))
+
+			job, err := kube.ClientSet.BatchV1().Jobs(key.Namespace).Get(context.Background(), getRunName(key.Name, runID)+"-plan", metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(job.Spec.Suspend).ToNot(BeNil())
+			Expect(*job.Spec.Suspend).To(BeTrue())
+		})
+
+		It("should time out a run that exceeded its timeout", func() {
+			key := types.NamespacedName{Name: "run-timeout", Namespace: "default"}
+
+			run := newRun(key.Name, "timeout.tfstate")
+			run.Spec.Timeout = &metav1.Duration{Duration: time.Minute}
+
+			Expect(k8sClient.Create(context.Background(), run)).Should(Succeed())
+
+			By("expect status to be started")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				return getTerraform(key).Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunStarted))
+
+			By("expect status to be timed out")
+			Eventually(func() v1alpha1.TerraformRunStatus {
+				r := getTerraform(key)
+
+				makeRunJobTimeOut(r)
+
+				return r.Status.RunStatus
+			}, timeout, interval).Should(Equal(v1alpha1.RunTimedOut))
+
+			r := getTerraform(key)
+			Expect(r.Status.FailureReason).To(Equal(v1alpha1.FailureTimedOut))
+			Expect(r.Status.Message).To(ContainSubstring("exceeded the timeout of 1m0s"))
+		})
+
+		It("should reject hooks with the same name at a lifecycle point", func() {
+			run := newRun("run-duplicate-hooks", "hooks.tfstate")
+			run.Spec.Hooks = &v1alpha1.Hooks{
+				PreApply: []v1alpha1.Hook{
+					{Name: "lint", Image: "ghcr.io/terraform-linters/tflint"},
+					{Name: "lint", Image: "bridgecrew/checkov"},
+				},
+			}
+
+			Expect(k8sClient.Create(context.Background(), run)).ShouldNot(Succeed())
+
+			run.Spec.Hooks.PreApply[1].Name = "checkov"
+			run.Spec.Hooks.PostApply = []v1alpha1.Hook{{Name: "lint", Image: "curlimages/curl"}}
+
+			Expect(k8sClient.Create(context.Background(), run)).Should(Succeed())
+			Expect(k8sClient.Delete(context.Background(), run)).Should(Succeed())
+		})
+	})
 })
@@ -9,9 +9,10 @@ import (
 
 // RecorderInterface is an interface that holds the functions used by the recorder struct
 type RecorderInterface interface {
-	RecordTotal(name string, namespace string)
-	RecordStatus(name string, namespace string, status v1alpha1.TerraformRunStatus)
-	RecordDuration(name string, namespace string, start time.Time)
+	RecordTotal(name string, namespace string, engine v1alpha1.TerraformEngine)
+	RecordStatus(name string, namespace string, engine v1alpha1.TerraformEngine, status v1alpha1.TerraformRunStatus)
+	RecordDuration(name string, namespace string, engine v1alpha1.TerraformEngine, start time.Time)
+	RecordRetry(name string, namespace string, engine v1alpha1.TerraformEngine)
 	Collectors() []prometheus.Collector
 }
 
@@ -22,6 +23,7 @@ type Recorder struct {
 	totalCount        *prometheus.CounterVec
 	statusGauge       *prometheus.GaugeVec
 	durationHistogram *prometheus.HistogramVec
+	retryCount        *prometheus.CounterVec
 }
 
 // NewRecorder returns a new Recorder with all metric names configured confirm GitOps Toolkit standards.
@@ -32,14 +34,14 @@ func NewRecorder() RecorderInterface {
 				Name: "tfo_workflow_total",
 				Help: "The total number of submitted workflows",
 			},
-			[]string{"name", "namespace"},
+			[]string{"name", "namespace", "engine"},
 		),
 		statusGauge: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "tfo_workflow_status",
 				Help: "The current status of a Terraform workflow/run resource reconciliation.",
 			},
-			[]string{"name", "namespace"},
+			[]string{"name", "namespace", "engine"},
 		),
 		durationHistogram: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
@@ -47,7 +49,14 @@ func NewRecorder() RecorderInterface {
 				Help:    "The duration in seconds of a Terraform workflow/run.",
 				Buckets: prometheus.ExponentialBuckets(10e-9, 10, 10),
 			},
-			[]string{"name", "namespace"},
+			[]string{"name", "namespace", "engine"},
+		),
+		retryCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tfo_workflow_retries_total",
+				Help: "The total number of retried workflows/runs based on the retry policy.",
+			},
+			[]string{"name", "namespace", "engine"},
 		),
 	}
 }
@@ -58,30 +67,36 @@ func (r *Recorder) Collectors() []prometheus.Collector {
 		r.totalCount,
 		r.statusGauge,
 		r.durationHistogram,
+		r.retryCount,
 	}
 }
 
 // RecordTotal records the total number of submitted workflows
-func (r *Recorder) RecordTotal(name string, namespace string) {
-	r.totalCount.WithLabelValues(name, namespace).Inc()
+func (r *Recorder) RecordTotal(name string, namespace string, engine v1alpha1.TerraformEngine) {
+	r.totalCount.WithLabelValues(name, namespace, string(engine)).Inc()
 }
 
 // RecordStatus records the status for a given terraform workflow/run
-func (r *Recorder) RecordStatus(name string, namespace string, status v1alpha1.TerraformRunStatus) {
+func (r *Recorder) RecordStatus(name string, namespace string, engine v1alpha1.TerraformEngine, status v1alpha1.TerraformRunStatus) {
 	var value float64
 
-	if status == v1alpha1.RunWaitingForDependency {
+	if status == v1alpha1.RunWaitingForDependency || status == v1alpha1.RunWaitingForLock {
 		value = -1
 	}
 
-	if status == v1alpha1.RunFailed {
+	if status == v1alpha1.RunFailed || status == v1alpha1.RunTimedOut || status == v1alpha1.RunBlocked {
 		value = 1
 	}
 
-	r.statusGauge.WithLabelValues(name, namespace).Set(value)
+	r.statusGauge.WithLabelValues(name, namespace, string(engine)).Set(value)
 }
 
 // RecordDuration records the duration since start for the given ref.
-func (r *Recorder) RecordDuration(name string, namespace string, start time.Time) {
-	r.durationHistogram.WithLabelValues(name, namespace).Observe(time.Since(start).Seconds())
+func (r *Recorder) RecordDuration(name string, namespace string, engine v1alpha1.TerraformEngine, start time.Time) {
+	r.durationHistogram.WithLabelValues(name, namespace, string(engine)).Observe(time.Since(start).Seconds())
+}
+
+// RecordRetry records a retry of a failed terraform workflow/run
+func (r *Recorder) RecordRetry(name string, namespace string, engine v1alpha1.TerraformEngine) {
+	r.retryCount.WithLabelValues(name, namespace, string(engine)).Inc()
 }
@@ -19,11 +19,12 @@ var _ = Describe("Metrics Recorder", func() {
 	const (
 		name      = "terraform-workflow"
 		namespace = "default"
+		engine    = v1alpha1.EngineTerraform
 	)
 
 	Context("Recording Total", func() {
 		It("should record the total count metric", func() {
-			rec.RecordTotal(name, namespace)
+			rec.RecordTotal(name, namespace, engine)
 
 			var (
 				value      float64 = 1.0
@@ -38,12 +39,31 @@ var _ = Describe("Metrics Recorder", func() {
 			Expect(metricFamilies[0].Metric).To(HaveLen(1))
 			Expect(metricFamilies[0].Metric[0].Counter).ToNot(BeNil())
 			Expect(metricFamilies[0].Metric[0].Counter.Value).To(Equal(&value))
+			Expect(metricFamilies[0].Metric[0].Label).To(HaveLen(3))
 		})
 	})
 
 	Context("Recording Status", func() {
 		It("should record the waitingForDependency status", func() {
-			rec.RecordStatus(name, namespace, v1alpha1.RunWaitingForDependency)
+			rec.RecordStatus(name, namespace, engine, v1alpha1.RunWaitingForDependency)
+
+			var (
+				value      float64 = -1.0
+				metricName string  = "tfo_workflow_status"
+			)
+
+			metricFamilies, err := reg.Gather()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(2))
+			Expect(metricFamilies[0].Name).To(Equal(&metricName))
+			Expect(metricFamilies[0].Metric).To(HaveLen(1))
+			Expect(metricFamilies[0].Metric[0].Gauge).ToNot(BeNil())
+			Expect(metricFamilies[0].Metric[0].Gauge.Value).To(Equal(&value))
+		})
+
+		It("should record the waitingForLock status as waiting", func() {
+			rec.RecordStatus(name, namespace, engine, v1alpha1.RunWaitingForLock)
 
 			var (
 				value      float64 = -1.0
@@ -61,7 +81,43 @@ var _ = Describe("Metrics Recorder", func() {
 		})
 
 		It("should record the failed status", func() {
-			rec.RecordStatus(name, namespace, v1alpha1.RunFailed)
+			rec.RecordStatus(name, namespace, engine, v1alpha1.RunFailed)
+
+			var (
+				value      float64 = 1.0
+				metricName string  = "tfo_workflow_status"
+			)
+
+			metricFamilies, err := reg.Gather()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(2))
+			Expect(metricFamilies[0].Name).To(Equal(&metricName))
+			Expect(metricFamilies[0].Metric).To(HaveLen(1))
+			Expect(metricFamilies[0].Metric[0].Gauge).ToNot(BeNil())
+			Expect(metricFamilies[0].Metric[0].Gauge.Value).To(Equal(&value))
+		})
+
+		It("should record the timed out status as a failure", func() {
+			rec.RecordStatus(name, namespace, engine, v1alpha1.RunTimedOut)
+
+			var (
+				value      float64 = 1.0
+				metricName string  = "tfo_workflow_status"
+			)
+
+			metricFamilies, err := reg.Gather()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(2))
+			Expect(metricFamilies[0].Name).To(Equal(&metricName))
+			Expect(metricFamilies[0].Metric).To(HaveLen(1))
+			Expect(metricFamilies[0].Metric[0].Gauge).ToNot(BeNil())
+			Expect(metricFamilies[0].Metric[0].Gauge.Value).To(Equal(&value))
+		})
+
+		It("should record the blocked status as a failure", func() {
+			rec.RecordStatus(name, namespace, engine, v1alpha1.RunBlocked)
 
 			var (
 				value      float64 = 1.0
@@ -79,7 +135,7 @@ var _ = Describe("Metrics Recorder", func() {
 		})
 
 		It("should record the completed status", func() {
-			rec.RecordStatus(name, namespace, v1alpha1.RunCompleted)
+			rec.RecordStatus(name, namespace, engine, v1alpha1.RunCompleted)
 
 			var (
 				value      float64 = 0.0
@@ -100,7 +156,7 @@ var _ = Describe("Metrics Recorder", func() {
 
 	Context("Recording Duration", func() {
 		It("should record the duration metric", func() {
-			rec.RecordDuration(name, namespace, time.Now())
+			rec.RecordDuration(name, namespace, engine, time.Now())
 
 			var (
 				metricName string = "tfo_workflow_duration_seconds"
@@ -115,4 +171,23 @@ var _ = Describe("Metrics Recorder", func() {
 			Expect(metricFamilies[0].Metric[0].Histogram).ToNot(BeNil())
 		})
 	})
+	Context("Recording Retries", func() {
+		It("should record the retry count metric", func() {
+			rec.RecordRetry(name, namespace, engine)
+
+			var (
+				value      float64 = 1.0
+				metricName string  = "tfo_workflow_retries_total"
+			)
+
+			metricFamilies, err := reg.Gather()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(metricFamilies).To(HaveLen(4))
+			Expect(metricFamilies[1].Name).To(Equal(&metricName))
+			Expect(metricFamilies[1].Metric).To(HaveLen(1))
+			Expect(metricFamilies[1].Metric[0].Counter).ToNot(BeNil())
+			Expect(metricFamilies[1].Metric[0].Counter.Value).To(Equal(&value))
+		})
+	})
 })
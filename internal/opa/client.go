@@ -0,0 +1,187 @@
+package opa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the address of the OPA sidecar of the operator
+const DefaultURL string = "http://localhost:8181"
+
+// policyIDPrefix prefixes the ids of the modules the operator loads into OPA
+const policyIDPrefix string = "terraform-operator"
+
+var packagePattern = regexp.MustCompile(`(?m)^\s*package\s+([A-Za-z0-9_.]+)`)
+
+// Client evaluates Rego policies with the REST API of an OPA server
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client of the OPA server at the given URL, the sidecar URL is used if it's empty
+func NewClient(serverURL string) *Client {
+	if serverURL == "" {
+		serverURL = DefaultURL
+	}
+
+	return &Client{
+		URL:        strings.TrimSuffix(serverURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// getPackage returns the package a Rego module declares
+func getPackage(module string) (string, error) {
+	match := packagePattern.FindStringSubmatch(module)
+
+	if match == nil {
+		return "", fmt.Errorf("the policy does not declare a package")
+	}
+
+	return match[1], nil
+}
+
+// formatResult returns a deny result as a message, results that are not strings are returned as JSON
+func formatResult(result interface{}) string {
+	if message, ok := result.(string); ok {
+		return message
+	}
+
+	data, _ := json.Marshal(result)
+
+	return string(data)
+}
+
+// do sends a request to the OPA server and decodes the response into out if it's set
+func (c *Client) do(ctx context.Context, method string, path string, contentType string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := c.HTTPClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("opa returned %d on %s %s: %s", res.StatusCode, method, path, strings.TrimSpace(string(data)))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+// Evaluate loads the modules into OPA and evaluates the 'deny' rule of their packages with the given input.
+// The modules are keyed by name and removed once evaluated, the deny results are returned prefixed with the
+// package that produced them
+func (c *Client) Evaluate(ctx context.Context, modules map[string]string, input json.RawMessage) ([]string, error) {
+	names := []string{}
+
+	for name := range modules {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	packages := []string{}
+	loaded := []string{}
+
+	defer func() {
+		for _, id := range loaded {
+			// a module left behind is replaced on the next evaluation
+			_ = c.do(context.Background(), http.MethodDelete, "/v1/policies/"+id, "", nil, nil)
+		}
+	}()
+
+	for _, name := range names {
+		pkg, err := getPackage(modules[name])
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the policy %s: %w", name, err)
+		}
+
+		id := policyIDPrefix + "/" + name
+
+		if err := c.do(ctx, http.MethodPut, "/v1/policies/"+id, "text/plain", []byte(modules[name]), nil); err != nil {
+			return nil, fmt.Errorf("failed to load the policy %s: %w", name, err)
+		}
+
+		loaded = append(loaded, id)
+
+		if !contains(packages, pkg) {
+			packages = append(packages, pkg)
+		}
+	}
+
+	body, err := json.Marshal(map[string]json.RawMessage{"input": input})
+
+	if err != nil {
+		return nil, err
+	}
+
+	violations := []string{}
+
+	for _, pkg := range packages {
+		out := struct {
+			Result interface{} `json:"result"`
+		}{}
+
+		path := "/v1/data/" + strings.ReplaceAll(pkg, ".", "/") + "/deny"
+
+		if err := c.do(ctx, http.MethodPost, path, "application/json", body, &out); err != nil {
+			return nil, fmt.Errorf("failed to evaluate the package %s: %w", pkg, err)
+		}
+
+		switch result := out.Result.(type) {
+		case []interface{}:
+			for _, item := range result {
+				violations = append(violations, fmt.Sprintf("%s: %s", pkg, formatResult(item)))
+			}
+		case bool:
+			// a boolean deny rule has no message
+			if result {
+				violations = append(violations, fmt.Sprintf("%s: denied", pkg))
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+// contains evaluates if the list has the value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
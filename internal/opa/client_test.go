@@ -0,0 +1,89 @@
+package opa
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OPA Client", func() {
+	var server *httptest.Server
+	var policies map[string]string
+	var inputs []string
+
+	BeforeEach(func() {
+		policies = map[string]string{}
+		inputs = []string{}
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			switch {
+			case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/v1/policies/"):
+				if strings.Contains(string(body), "invalid") {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				policies[strings.TrimPrefix(r.URL.Path, "/v1/policies/")] = string(body)
+			case r.Method == http.MethodDelete:
+				delete(policies, strings.TrimPrefix(r.URL.Path, "/v1/policies/"))
+			case r.URL.Path == "/v1/data/terraform/s3/deny":
+				inputs = append(inputs, string(body))
+				_, _ = w.Write([]byte(`{"result": ["bucket is public", {"resource": "aws_s3_bucket.logs"}]}`))
+			case r.URL.Path == "/v1/data/terraform/flag/deny":
+				_, _ = w.Write([]byte(`{"result": true}`))
+			default:
+				_, _ = w.Write([]byte(`{}`))
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should default to the sidecar URL", func() {
+		Expect(NewClient("").URL).To(Equal(DefaultURL))
+		Expect(NewClient("http://opa:8181/").URL).To(Equal("http://opa:8181"))
+	})
+
+	It("should return the deny results of every package and remove the modules", func() {
+		modules := map[string]string{
+			"default/policies/s3.rego":      "package terraform.s3\n\ndeny[msg] { msg := \"bucket is public\" }",
+			"default/policies/s3-more.rego": "package terraform.s3\n\ndeny[msg] { false; msg := \"\" }",
+			"default/policies/tags.rego":    "package terraform.tags\n\ndeny[msg] { false; msg := \"\" }",
+			"default/policies/flag.rego":    "package terraform.flag\n\ndeny { true }",
+		}
+
+		violations, err := NewClient(server.URL).Evaluate(context.Background(), modules, json.RawMessage(`{"format_version": "1.0"}`))
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(violations).To(ConsistOf(
+			"terraform.s3: bucket is public",
+			`terraform.s3: {"resource":"aws_s3_bucket.logs"}`,
+			"terraform.flag: denied",
+		))
+		Expect(inputs).To(Equal([]string{`{"input":{"format_version":"1.0"}}`}))
+		Expect(policies).To(BeEmpty())
+	})
+
+	It("should fail on a module without a package", func() {
+		_, err := NewClient(server.URL).Evaluate(context.Background(), map[string]string{"a.rego": "deny[msg] { true }"}, json.RawMessage(`{}`))
+
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fail on a module OPA does not accept", func() {
+		_, err := NewClient(server.URL).Evaluate(context.Background(), map[string]string{"a.rego": "package invalid"}, json.RawMessage(`{}`))
+
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("400"))
+	})
+})
@@ -3,6 +3,7 @@ package utils
 import (
 	"log"
 	"os"
+	"strings"
 )
 
 // EnvConfig holds the environment variables information
@@ -10,12 +11,33 @@ type EnvConfig struct {
 	DockerRepository        string
 	TerraformRunnerImage    string
 	TerraformRunnerImageTag string
+	RunnerCapabilities      []string
 	KnownHostsConfigMapName string
+	TerraformVersions       []string
+	OpenTofuRunnerImage     string
+	OpenTofuRunnerImageTag  string
+	OpenTofuVersions        []string
+	OperatorNamespace       string
+	OPAURL                  string
+	MandatoryPolicies       []string
 }
 
 // Env holds the values of the environment variables
 var Env *EnvConfig
 
+// defaultTerraformVersions holds the Terraform versions a version constraint is resolved from if
+// TERRAFORM_VERSIONS is not set, the latest patch of each minor release. The list is not fetched from the
+// releases, it must be bumped with every operator release
+var defaultTerraformVersions = []string{
+	"0.12.31", "0.13.7", "0.14.11", "0.15.5", "1.0.11", "1.1.9", "1.2.9",
+	"1.3.10", "1.4.7", "1.5.7", "1.6.6", "1.7.5", "1.8.5", "1.9.8",
+}
+
+// defaultOpenTofuVersions holds the OpenTofu versions a version constraint is resolved from if
+// OPENTOFU_VERSIONS is not set, the latest patch of each minor release. It must be bumped with every
+// operator release as well
+var defaultOpenTofuVersions = []string{"1.6.3", "1.7.8", "1.8.8"}
+
 // getEnvOrPanic returns a required environment variable and panics if it does not exist
 func getEnvOrPanic(name string) string {
 	env, present := os.LookupEnv(name)
@@ -38,6 +60,29 @@ func getEnvOptional(name string) string {
 	return ""
 }
 
+// getEnvList returns an optional comma separated environment variable as a list
+func getEnvList(name string) []string {
+	list := []string{}
+
+	for _, item := range strings.Split(getEnvOptional(name), ",") {
+		if value := strings.TrimSpace(item); value != "" {
+			list = append(list, value)
+		}
+	}
+
+	return list
+}
+
+// getEnvListOrDefault returns an optional comma separated environment variable as a list, the defaults are
+// returned if it is not set
+func getEnvListOrDefault(name string, defaults []string) []string {
+	if list := getEnvList(name); len(list) > 0 {
+		return list
+	}
+
+	return append([]string{}, defaults...)
+}
+
 // LoadEnv loads teh environment variables
 func LoadEnv() {
 	cfg := &EnvConfig{}
@@ -45,8 +90,15 @@ func LoadEnv() {
 	cfg.DockerRepository = getEnvOrPanic("DOCKER_REGISTRY")
 	cfg.TerraformRunnerImage = getEnvOrPanic("TERRAFORM_RUNNER_IMAGE")
 	cfg.TerraformRunnerImageTag = getEnvOrPanic("TERRAFORM_RUNNER_IMAGE_TAG")
-	cfg.TerraformRunnerImageTag = getEnvOrPanic("TERRAFORM_RUNNER_IMAGE_TAG")
+	cfg.RunnerCapabilities = getEnvList("TERRAFORM_RUNNER_CAPABILITIES")
 	cfg.KnownHostsConfigMapName = getEnvOptional("KNOWN_HOSTS_CONFIGMAP_NAME")
+	cfg.TerraformVersions = getEnvListOrDefault("TERRAFORM_VERSIONS", defaultTerraformVersions)
+	cfg.OpenTofuRunnerImage = getEnvOptional("OPENTOFU_RUNNER_IMAGE")
+	cfg.OpenTofuRunnerImageTag = getEnvOptional("OPENTOFU_RUNNER_IMAGE_TAG")
+	cfg.OpenTofuVersions = getEnvListOrDefault("OPENTOFU_VERSIONS", defaultOpenTofuVersions)
+	cfg.OperatorNamespace = getEnvOptional("OPERATOR_NAMESPACE")
+	cfg.OPAURL = getEnvOptional("OPA_URL")
+	cfg.MandatoryPolicies = getEnvList("MANDATORY_POLICY_CONFIGMAPS")
 
 	Env = cfg
 }
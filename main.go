@@ -47,6 +47,7 @@ var (
 	setupLog          = ctrl.Log.WithName("setup")
 	requeueDependency time.Duration
 	requeueJobWatch   time.Duration
+	historySweep      time.Duration
 )
 
 func init() {
@@ -60,10 +61,14 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var enableWebhooks bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.DurationVar(&requeueJobWatch, "requeue-job-watch", 10*time.Second, "The interval at which job status is reevaluated after a workflow is submitted.")
 	flag.DurationVar(&requeueDependency, "requeue-dependency", 20*time.Second, "The interval at which dependencies are reevaluated.")
+	flag.DurationVar(&historySweep, "history-sweep-interval", 10*time.Minute, "The interval at which the jobs and configmaps of previous runs are garbage collected, 0 disables the periodic sweep.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Enable the validating webhook that rejects Terraform objects claiming the same state. "+
+		"The webhook server requires a serving certificate.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -94,9 +99,11 @@ func main() {
 
 	setupLog.Info(fmt.Sprintf("requeue dependency interval: %s", requeueDependency))
 	setupLog.Info(fmt.Sprintf("requeue job watch interval: %s", requeueJobWatch))
+	setupLog.Info(fmt.Sprintf("history sweep interval: %s", historySweep))
 
 	if err = (&controllers.TerraformReconciler{
 		Client:          mgr.GetClient(),
+		APIReader:       mgr.GetAPIReader(),
 		Scheme:          mgr.GetScheme(),
 		Recorder:        mgr.GetEventRecorderFor("terraform-controller"),
 		MetricsRecorder: metricsRecorder,
@@ -104,10 +111,18 @@ func main() {
 	}).SetupWithManager(mgr, controllers.TerraformReconcilerOptions{
 		RequeueDependencyInterval: requeueDependency,
 		RequeueJobWatchInterval:   requeueJobWatch,
+		HistorySweepInterval:      historySweep,
 	}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Terraform")
 		os.Exit(1)
 	}
+
+	if enableWebhooks {
+		if err = (&v1alpha1.Terraform{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Terraform")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err = mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {